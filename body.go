@@ -0,0 +1,215 @@
+package har
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"strings"
+	"unicode/utf8"
+)
+
+// SetBody sets the posted data of the request to the given body.
+//
+// size is the actual size of the body in bytes.
+// If body holds fewer bytes than that, or more bytes than allowed by
+// WithMaxBodySize, the body is considered truncated,
+// which is marked on the posted data.
+//
+// Binary bodies are base64 encoded, which is marked in the custom "_encoding"
+// field of the posted data.
+//
+// URL encoded and multipart forms are additionally parsed into parameters.
+func (r *Request) SetBody(body []byte, size int, opts ...ConvertOption) {
+	options := newConvertOptions(opts)
+	body = options.truncate(body)
+
+	mimeType := mimeTypeFromHeaders(r.Headers)
+	if len(body) < size {
+		body = trimIncompleteRune(mimeType, body)
+	}
+	text, encoding := encodeBody(mimeType, body)
+
+	r.PostData = &PostData{
+		MimeType: mimeType,
+		Params:   paramsFromBody(mimeType, body),
+		Text:     text,
+		Encoding: encoding,
+	}
+	r.BodySize = size
+
+	if len(body) < size {
+		r.PostData.Truncated = true
+		r.PostData.Comment = truncatedComment(len(body), size)
+	}
+}
+
+// SetBody sets the content of the response to the given body,
+// as it was received from the server.
+//
+// size is the actual size of the body in bytes.
+// If body holds fewer bytes than that, or the content holds more bytes than
+// allowed by WithMaxBodySize, the content is considered truncated,
+// which is marked on the content.
+//
+// If the body is encoded according to the Content-Encoding header,
+// e.g. with gzip, the decoded body is used as content, and the number of bytes
+// saved by the encoding is stored as compression.
+// See RegisterDecoder for the supported encodings.
+// If the body cannot be decoded, e.g. because the encoding is not supported,
+// or because too little of it was recorded, the content is left out,
+// its size is set to -1, and a comment says why.
+//
+// Binary content is base64 encoded, as described by the spec.
+func (r *Response) SetBody(body []byte, size int, opts ...ConvertOption) {
+	options := newConvertOptions(opts)
+
+	// The body size is the size of the body as it was transferred.
+	r.BodySize = size
+	partial := len(body) < size
+
+	content := options.truncate(body)
+	contentSize := size
+
+	// Empty bodies, e.g. of HEAD requests and 304 responses, are not encoded,
+	// whatever their Content-Encoding says.
+	var encodings []string
+	if len(body) > 0 || size > 0 {
+		encodings = contentEncodings(headerValue(r.Headers, "Content-Encoding"))
+	}
+	if len(encodings) > 0 {
+		decoded, decodedSize, ok := decodeContent(encodings, body, options.maxBodySize, partial)
+		if !ok {
+			// The encoded body is not the content, and neither is its size.
+			r.Content.Text = ""
+			r.Content.Encoding = ""
+			r.Content.Size = -1
+			r.Content.Comment = fmt.Sprintf(
+				"the content could not be decoded from its %s Content-Encoding",
+				strings.Join(encodings, ", "),
+			)
+			return
+		}
+
+		content = decoded
+		contentSize = decodedSize
+		// Encoding small bodies can make them larger, which is not
+		// a saving, and compression must not be negative.
+		if !partial && decodedSize > size {
+			r.Content.Compression = decodedSize - size
+		}
+	}
+
+	if partial || len(content) < contentSize {
+		content = trimIncompleteRune(r.Content.MimeType, content)
+	}
+
+	r.Content.Text, r.Content.Encoding = encodeBody(r.Content.MimeType, content)
+	r.Content.Size = contentSize
+
+	switch {
+	case len(encodings) > 0 && partial:
+		// We do not know the size of the decoded content,
+		// only how much of it we were able to decode.
+		r.Content.Truncated = true
+		r.Content.Comment = fmt.Sprintf(
+			"truncated to %d of at least %d bytes",
+			len(content),
+			contentSize,
+		)
+
+	case len(content) < contentSize:
+		r.Content.Truncated = true
+		r.Content.Comment = truncatedComment(len(content), contentSize)
+	}
+}
+
+func truncatedComment(recorded int, size int) string {
+	return fmt.Sprintf("truncated to %d of %d bytes", recorded, size)
+}
+
+// encodeBody returns the text representation of body,
+// along with the encoding used for it, if any.
+//
+// The body is base64 encoded if the MIME type is known to be binary,
+// or if the body is not valid UTF-8 and would be mangled as a JSON string.
+func encodeBody(mimeType string, body []byte) (text string, encoding string) {
+	if isBinaryMimeType(mimeType) || !utf8.Valid(body) {
+		return base64.StdEncoding.EncodeToString(body), "base64"
+	}
+	return string(body), ""
+}
+
+// trimIncompleteRune removes an incomplete character from the end of a text
+// body that was cut short, so that it is not mistaken for binary data.
+// Bodies that are binary, or not valid UTF-8 before their last character,
+// are returned as is.
+func trimIncompleteRune(mimeType string, body []byte) []byte {
+	if isBinaryMimeType(mimeType) || utf8.Valid(body) {
+		return body
+	}
+
+	// The last character starts at most utf8.UTFMax-1 bytes from the end,
+	// if it is incomplete.
+	for i := len(body) - 1; i >= 0 && i > len(body)-utf8.UTFMax; i-- {
+		if !utf8.RuneStart(body[i]) {
+			continue
+		}
+
+		if !utf8.FullRune(body[i:]) && utf8.Valid(body[:i]) {
+			return body[:i]
+		}
+		break
+	}
+
+	return body
+}
+
+// isBinaryMimeType reports whether the MIME type is known to describe
+// binary data.
+// Unknown MIME types are not considered binary.
+func isBinaryMimeType(mimeType string) bool {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return false
+	}
+
+	category, subtype, _ := strings.Cut(mediaType, "/")
+
+	switch category {
+	case "image":
+		// SVG images are XML documents.
+		return subtype != "svg+xml"
+
+	case "audio", "video", "font":
+		return true
+
+	case "application":
+		if strings.HasPrefix(subtype, "grpc") ||
+			strings.HasSuffix(subtype, "+proto") ||
+			strings.HasSuffix(subtype, "+protobuf") {
+			return true
+		}
+
+		switch subtype {
+		case "octet-stream",
+			"pdf",
+			"zip",
+			"gzip",
+			"x-gzip",
+			"zstd",
+			"x-tar",
+			"x-7z-compressed",
+			"x-bzip2",
+			"protobuf",
+			"x-protobuf",
+			"vnd.google.protobuf",
+			"msgpack",
+			"x-msgpack",
+			"cbor",
+			"wasm":
+			return true
+		}
+	}
+
+	return false
+}
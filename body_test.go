@@ -0,0 +1,196 @@
+package har
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+func TestRequestSetBodyTruncatedText(t *testing.T) {
+	request := Request{
+		Headers: []Header{{Name: "Content-Type", Value: "text/plain; charset=utf-8"}},
+	}
+
+	// "é" is two bytes long, so the limit cuts it in half.
+	body := []byte("hé")
+	request.SetBody(body, len(body), WithMaxBodySize(2))
+
+	postData := request.PostData
+	if postData.Text != "h" || postData.Encoding != "" {
+		t.Errorf("Text, Encoding = %q, %q, want %q, %q", postData.Text, postData.Encoding, "h", "")
+	}
+	if !postData.Truncated {
+		t.Error("Truncated = false, want true")
+	}
+}
+
+func TestResponseSetBodyTruncatedText(t *testing.T) {
+	tests := []struct {
+		name     string
+		mimeType string
+		body     string
+		limit    int
+		text     string
+		encoding string
+	}{
+		{
+			name:     "incomplete character",
+			mimeType: "text/plain",
+			body:     "日本語",
+			limit:    4,
+			text:     "日",
+		},
+		{
+			name:     "complete character",
+			mimeType: "text/plain",
+			body:     "日本語",
+			limit:    6,
+			text:     "日本",
+		},
+		{
+			name:     "binary",
+			mimeType: "application/octet-stream",
+			body:     "日本語",
+			limit:    4,
+			text:     "5pel5g==",
+			encoding: "base64",
+		},
+		{
+			name:     "invalid before the limit",
+			mimeType: "text/plain",
+			body:     "\xff日本語",
+			limit:    5,
+			text:     "/+aXpeY=",
+			encoding: "base64",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := Response{
+				Content: Content{MimeType: test.mimeType},
+			}
+			response.SetBody([]byte(test.body), len(test.body), WithMaxBodySize(test.limit))
+
+			content := response.Content
+			if content.Text != test.text || content.Encoding != test.encoding {
+				t.Errorf(
+					"Text, Encoding = %q, %q, want %q, %q",
+					content.Text, content.Encoding, test.text, test.encoding,
+				)
+			}
+			if content.Size != len(test.body) || !content.Truncated {
+				t.Errorf("Size, Truncated = %d, %v, want %d, true", content.Size, content.Truncated, len(test.body))
+			}
+		})
+	}
+}
+
+func TestResponseSetBodyUndecodable(t *testing.T) {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, _ = writer.Write(bytes.Repeat([]byte("a"), 600))
+	_ = writer.Close()
+	body := compressed.Bytes()
+
+	tests := []struct {
+		name            string
+		contentEncoding string
+		body            []byte
+	}{
+		{
+			name:            "too little recorded",
+			contentEncoding: "gzip",
+			body:            body[:8],
+		},
+		{
+			name:            "unsupported encoding",
+			contentEncoding: "unsupported",
+			body:            body,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := Response{
+				Headers: []Header{{Name: "Content-Encoding", Value: test.contentEncoding}},
+				Content: Content{MimeType: "text/plain"},
+			}
+			response.SetBody(test.body, len(body))
+
+			content := response.Content
+			if content.Text != "" || content.Encoding != "" {
+				t.Errorf("Text, Encoding = %q, %q, want none", content.Text, content.Encoding)
+			}
+			if content.Size != -1 {
+				t.Errorf("Size = %d, want -1", content.Size)
+			}
+			if content.Comment == "" {
+				t.Error("Comment is empty, want an explanation")
+			}
+			if response.BodySize != len(body) {
+				t.Errorf("BodySize = %d, want %d", response.BodySize, len(body))
+			}
+		})
+	}
+}
+
+func TestResponseSetBodyDecoded(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		compression func(encoded int) int
+	}{
+		{
+			name:        "compressible",
+			content:     strings.Repeat("a", 600),
+			compression: func(encoded int) int { return 600 - encoded },
+		},
+		{
+			// The gzip header makes small bodies larger,
+			// which is not recorded as a negative compression.
+			name:        "small",
+			content:     `{"a":1}`,
+			compression: func(int) int { return 0 },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var compressed bytes.Buffer
+			writer := gzip.NewWriter(&compressed)
+			_, _ = writer.Write([]byte(test.content))
+			_ = writer.Close()
+			body := compressed.Bytes()
+
+			response := Response{
+				Headers: []Header{{Name: "Content-Encoding", Value: "gzip"}},
+				Content: Content{MimeType: "text/plain"},
+			}
+			response.SetBody(body, len(body))
+
+			content := response.Content
+			if content.Text != test.content || content.Size != len(test.content) {
+				t.Errorf("Text, Size = %q, %d, want %q, %d", content.Text, content.Size, test.content, len(test.content))
+			}
+			if want := test.compression(len(body)); content.Compression != want {
+				t.Errorf("Compression = %d, want %d", content.Compression, want)
+			}
+		})
+	}
+}
+
+func TestResponseSetBodyEmptyEncoded(t *testing.T) {
+	response := Response{
+		Status:  304,
+		Headers: []Header{{Name: "Content-Encoding", Value: "gzip"}},
+		Content: Content{MimeType: "text/plain"},
+	}
+	response.SetBody([]byte{}, 0)
+
+	content := response.Content
+	if content.Size != 0 || content.Text != "" || content.Comment != "" {
+		t.Errorf("Size, Text, Comment = %d, %q, %q, want 0 and none", content.Size, content.Text, content.Comment)
+	}
+}
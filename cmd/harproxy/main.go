@@ -0,0 +1,134 @@
+// Command harproxy is an HTTP forward proxy that records every exchange it
+// proxies, so that traffic can be captured from any client that supports
+// HTTP_PROXY, regardless of the language it is written in.
+//
+// Plain HTTP requests are recorded in their entirety.
+// HTTPS requests are tunneled with CONNECT without being intercepted,
+// so only the timing and the amount of data of each tunnel is recorded.
+//
+// Usage:
+//
+//	harproxy -addr localhost:8080 -out out/capture.jsonl -har out/capture.har
+//	HTTP_PROXY=http://localhost:8080 HTTPS_PROXY=http://localhost:8080 curl ...
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"syscall"
+	"time"
+
+	"github.com/oliverroer/go-har"
+	harwriter "github.com/oliverroer/go-har/writer"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	out := flag.String("out", "", "entry file to write to (default out/<timestamp>.jsonl)")
+	harFile := flag.String("har", "", "HAR file to assemble from the entry file on exit")
+	maxBodySize := flag.Int("max-body-size", -1, "maximum number of body bytes to record, or -1 for no limit")
+	redact := flag.Bool("redact", false, "mask common secrets such as credentials, session cookies and tokens")
+	flag.Parse()
+
+	err := run(*addr, *out, *harFile, *maxBodySize, *redact)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(addr string, out string, harFile string, maxBodySize int, redact bool) error {
+	if out == "" {
+		dir := "out"
+		perm := fs.FileMode(0750)
+		err := os.MkdirAll(dir, perm)
+		if err != nil {
+			return err
+		}
+
+		out = path.Join(dir, harwriter.DefaultName()+".jsonl")
+	}
+
+	opts := []harwriter.Option{
+		harwriter.WithAsync(1024, harwriter.QueueBlock),
+	}
+
+	if redact {
+		redactor, err := har.NewRedactor(har.DefaultRedaction(har.RedactMask))
+		if err != nil {
+			return err
+		}
+		opts = append(opts, harwriter.WithRedactor(redactor))
+	}
+
+	writer, err := harwriter.Open(out, opts...)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	proxy := newProxy(writer, maxBodySize)
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           proxy,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	log.Printf("proxying on %s, recording to %s", addr, out)
+
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+		log.Print("shutting down")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		shutdownErr := server.Shutdown(shutdownCtx)
+		cancel()
+
+		// Connections that are still busy, e.g. with streaming responses,
+		// are cut off, so that what was recorded is still written.
+		if shutdownErr != nil {
+			log.Printf("shutting down: %v", shutdownErr)
+			_ = server.Close()
+		}
+	}
+
+	// Tunnels are not tracked by the server, since their connections have
+	// been hijacked.
+	proxy.closeTunnels()
+
+	err = errors.Join(ignoreClosed(err), writer.Close())
+	if err != nil {
+		return err
+	}
+
+	if harFile != "" {
+		err = harwriter.EntriesToHar(harFile, out)
+		if err != nil {
+			return err
+		}
+		log.Printf("wrote %s", harFile)
+	}
+
+	return nil
+}
+
+func ignoreClosed(err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
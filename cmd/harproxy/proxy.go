@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oliverroer/go-har"
+	harwriter "github.com/oliverroer/go-har/writer"
+)
+
+// proxy is an HTTP forward proxy that records the exchanges it proxies.
+type proxy struct {
+	writer    *harwriter.EntryWriter
+	transport http.RoundTripper
+	dialer    net.Dialer
+
+	// tunnels is done once all tunnels have been closed,
+	// which they are when ctx is canceled.
+	tunnels sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+func newProxy(writer *harwriter.EntryWriter, maxBodySize int) *proxy {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+
+	// Requests are sent directly, even if the proxy itself runs with
+	// HTTP_PROXY set, and bodies are passed on as they were received.
+	base.Proxy = nil
+	base.DisableCompression = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &proxy{
+		writer: writer,
+		transport: writer.RoundTripper(
+			base,
+			harwriter.WithStreaming(),
+			harwriter.WithMaxRequestBodySize(maxBodySize),
+			harwriter.WithMaxResponseBodySize(maxBodySize),
+		),
+		dialer: net.Dialer{
+			Timeout: 30 * time.Second,
+		},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (p *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+
+	if !r.URL.IsAbs() {
+		http.Error(w, "harproxy is a forward proxy, and only serves absolute URLs", http.StatusBadRequest)
+		return
+	}
+
+	p.forward(w, r)
+}
+
+// forward sends a plain HTTP request on to its destination,
+// through the recording transport.
+func (p *proxy) forward(w http.ResponseWriter, r *http.Request) {
+	req := r.Clone(r.Context())
+	req.RequestURI = ""
+	removeHopByHopHeaders(req.Header)
+
+	res, err := p.transport.RoundTrip(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer res.Body.Close()
+
+	removeHopByHopHeaders(res.Header)
+	for name, values := range res.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(res.StatusCode)
+
+	err = copyFlushing(w, res.Body)
+	if err != nil {
+		log.Printf("%s %s: %v", r.Method, r.URL, err)
+	}
+}
+
+// copyFlushing copies the body to w, flushing after every read,
+// so that streaming responses reach the client as they arrive.
+func copyFlushing(w http.ResponseWriter, body io.Reader) error {
+	controller := http.NewResponseController(w)
+	buffer := make([]byte, 32*1024)
+
+	for {
+		n, err := body.Read(buffer)
+		if n > 0 {
+			if _, writeErr := w.Write(buffer[:n]); writeErr != nil {
+				return writeErr
+			}
+			_ = controller.Flush()
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// hopByHopHeaders are the headers that only apply to a single connection,
+// and are not passed on by proxies.
+var hopByHopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+func removeHopByHopHeaders(header http.Header) {
+	// Connection lists additional headers that apply to the connection.
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			header.Del(strings.TrimSpace(name))
+		}
+	}
+
+	for _, name := range hopByHopHeaders {
+		header.Del(name)
+	}
+}
+
+// tunnel connects the client to the requested host, and passes data back and
+// forth without looking at it.
+// The tunnel is recorded as an entry with its timings and the number of bytes
+// sent each way, once it is closed.
+func (p *proxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	p.tunnels.Add(1)
+	defer p.tunnels.Done()
+
+	start := time.Now()
+	harRequest := har.RequestFromHttpRequest(r, har.WithoutBody())
+	harRequest.URL = "https://" + r.Host
+
+	upstream, err := p.dialer.DialContext(r.Context(), "tcp", r.Host)
+	connected := time.Now()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		p.writeTunnelEntry(start, connected, connected, harRequest, har.ResponseFromError(err), nil)
+		return
+	}
+	defer upstream.Close()
+
+	client, buffered, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer client.Close()
+
+	const established = "HTTP/1.1 200 Connection Established\r\n\r\n"
+	_, err = client.Write([]byte(established))
+	if err != nil {
+		return
+	}
+
+	// Close both connections when the proxy shuts down.
+	stop := context.AfterFunc(p.ctx, func() {
+		_ = client.Close()
+		_ = upstream.Close()
+	})
+	defer stop()
+
+	var sent, received atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		// The client may have sent data along with the CONNECT request.
+		early := io.LimitReader(buffered.Reader, int64(buffered.Reader.Buffered()))
+		sent.Store(pipe(upstream, io.MultiReader(early, client)))
+	}()
+	go func() {
+		defer wg.Done()
+		received.Store(pipe(client, upstream))
+	}()
+	wg.Wait()
+
+	harResponse := har.Response{
+		Status:      http.StatusOK,
+		StatusText:  "200 Connection Established",
+		HttpVersion: r.Proto,
+		Cookies:     []har.Cookie{},
+		Headers:     []har.Header{},
+		Content: har.Content{
+			Size:    int(received.Load()),
+			Comment: "the tunnel is not intercepted, so its content is not recorded",
+		},
+		HeadersSize: len(established),
+		BodySize:    int(received.Load()),
+	}
+	harRequest.BodySize = int(sent.Load())
+
+	p.writeTunnelEntry(start, connected, time.Now(), harRequest, harResponse, upstream)
+}
+
+// pipe copies from src to dst until src is done, and then closes dst for
+// writing, to pass the end of the stream on.
+// It returns the number of bytes copied.
+func pipe(dst net.Conn, src io.Reader) int64 {
+	n, _ := io.Copy(dst, src)
+
+	if closer, ok := dst.(interface{ CloseWrite() error }); ok {
+		_ = closer.CloseWrite()
+	} else {
+		_ = dst.Close()
+	}
+
+	return n
+}
+
+func (p *proxy) writeTunnelEntry(
+	start time.Time,
+	connected time.Time,
+	end time.Time,
+	harRequest har.Request,
+	harResponse har.Response,
+	upstream net.Conn,
+) {
+	timings := har.Timings{
+		Blocked: -1,
+		DNS:     -1,
+		Connect: millis(start, connected),
+		SSL:     -1,
+		Receive: millis(connected, end),
+	}
+
+	entry := har.Entry{
+		StartedDateTime: start,
+		Time:            timings.Total(),
+		Request:         harRequest,
+		Response:        harResponse,
+		Timings:         timings,
+	}
+
+	if upstream != nil {
+		if host, _, err := net.SplitHostPort(upstream.RemoteAddr().String()); err == nil {
+			entry.ServerIPAddress = host
+		}
+		if _, port, err := net.SplitHostPort(upstream.LocalAddr().String()); err == nil {
+			entry.Connection = port
+		}
+	}
+
+	err := p.writer.Write(entry)
+	if err != nil {
+		log.Printf("CONNECT %s: %v", harRequest.URL, err)
+	}
+}
+
+// closeTunnels closes all tunnels, and waits for their entries to be
+// written.
+func (p *proxy) closeTunnels() {
+	p.cancel()
+	p.tunnels.Wait()
+}
+
+// millis returns the number of milliseconds between from and to,
+// or 0 if to is before from.
+func millis(from, to time.Time) float64 {
+	duration := to.Sub(from)
+	if duration < 0 {
+		return 0
+	}
+	return float64(duration.Microseconds()) / 1000
+}
@@ -3,44 +3,85 @@ package har
 import (
 	"bytes"
 	"io"
+	"maps"
 	"net/http"
+	"net/url"
+	"slices"
 	"strings"
 )
 
-func RequestFromHttpRequest(req *http.Request) Request {
+// ConvertOption configures how a HTTP request or response is converted.
+type ConvertOption func(*convertOptions)
+
+type convertOptions struct {
+	withoutBody bool
+	maxBodySize int
+}
+
+// WithoutBody skips reading the body during conversion.
+// This is useful when the body is captured by other means, in which case it
+// can be added to the converted request or response using SetBody.
+func WithoutBody() ConvertOption {
+	return func(options *convertOptions) {
+		options.withoutBody = true
+	}
+}
+
+// WithMaxBodySize limits the number of body bytes that are recorded.
+// The body is still read in its entirety, so that its actual size is known,
+// but only the first n bytes are stored in the converted request or response.
+func WithMaxBodySize(n int) ConvertOption {
+	return func(options *convertOptions) {
+		options.maxBodySize = n
+	}
+}
+
+func newConvertOptions(opts []ConvertOption) convertOptions {
+	options := convertOptions{
+		maxBodySize: -1,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+func RequestFromHttpRequest(req *http.Request, opts ...ConvertOption) Request {
+	options := newConvertOptions(opts)
 	headerData := headerDataFromHttpHeader(req.Header)
 
 	request := Request{
 		Method:      req.Method,
 		URL:         req.URL.String(),
 		HTTPVersion: req.Proto,
-		Cookies:     []Cookie{}, // TODO: Implement cookies?
+		Cookies:     cookiesFromHttpRequest(req),
 		Headers:     headerData.headers,
-		QueryString: []QueryString{}, // TODO: Implement query string?
+		QueryString: queryStringFromURL(req.URL),
 		PostData:    nil,
 		HeadersSize: headerData.size,
 		BodySize:    -1,
 	}
 
+	if options.withoutBody {
+		return request
+	}
+
 	if body := peekBody(&req.Body); body != nil {
-		request.PostData = &PostData{
-			MimeType: headerData.mimeType,
-			Text:     string(body),
-		}
-		request.BodySize = len(body)
+		request.SetBody(body, len(body), opts...)
 	}
 
 	return request
 }
 
-func ResponseFromHttpResponse(res *http.Response) Response {
+func ResponseFromHttpResponse(res *http.Response, opts ...ConvertOption) Response {
+	options := newConvertOptions(opts)
 	headerData := headerDataFromHttpHeader(res.Header)
 
 	response := Response{
 		Status:      res.StatusCode,
 		StatusText:  res.Status,
 		HttpVersion: res.Proto,
-		Cookies:     []Cookie{}, // TODO: Implement cookies?
+		Cookies:     cookiesFromHttpResponse(res),
 		Headers:     headerData.headers,
 		Content: Content{
 			MimeType: headerData.mimeType,
@@ -51,14 +92,66 @@ func ResponseFromHttpResponse(res *http.Response) Response {
 		BodySize:    int(res.ContentLength),
 	}
 
+	if options.withoutBody {
+		return response
+	}
+
 	if body := peekBody(&res.Body); body != nil {
-		response.Content.Text = string(body)
-		response.Content.Size = len(body)
+		response.SetBody(body, len(body), opts...)
 	}
 
 	return response
 }
 
+// truncate returns the part of body that should be recorded.
+func (o convertOptions) truncate(body []byte) []byte {
+	if o.maxBodySize >= 0 && len(body) > o.maxBodySize {
+		return body[:o.maxBodySize]
+	}
+	return body
+}
+
+// queryStringFromURL returns the parameters of the query string of u,
+// in the order they appear in, including repeated and empty parameters.
+func queryStringFromURL(u *url.URL) []QueryString {
+	queryString := []QueryString{}
+
+	parseURLEncoded(u.RawQuery, func(name string, value string) {
+		queryString = append(queryString, QueryString{
+			Name:  name,
+			Value: value,
+		})
+	})
+
+	return queryString
+}
+
+// parseURLEncoded calls add with each of the percent-decoded name/value pairs
+// of a URL encoded string, in the order they appear in.
+func parseURLEncoded(encoded string, add func(name string, value string)) {
+	for encoded != "" {
+		var pair string
+		pair, encoded, _ = strings.Cut(encoded, "&")
+		if pair == "" {
+			continue
+		}
+
+		name, value, _ := strings.Cut(pair, "=")
+
+		add(unescapeQueryComponent(name), unescapeQueryComponent(value))
+	}
+}
+
+// unescapeQueryComponent percent-decodes a query string component,
+// or returns it as is, if it is not properly escaped.
+func unescapeQueryComponent(component string) string {
+	unescaped, err := url.QueryUnescape(component)
+	if err != nil {
+		return component
+	}
+	return unescaped
+}
+
 type headerData struct {
 	headers  []Header
 	size     int
@@ -67,7 +160,11 @@ type headerData struct {
 }
 
 func headerDataFromHttpHeader(httpHeader http.Header) headerData {
-	headerCount := len(httpHeader)
+	headerCount := 0
+	for _, values := range httpHeader {
+		headerCount += len(values)
+	}
+
 	data := headerData{
 		headers:  make([]Header, 0, headerCount),
 		size:     0,
@@ -79,38 +176,52 @@ func headerDataFromHttpHeader(httpHeader http.Header) headerData {
 		return data
 	}
 
-	for name, values := range httpHeader {
-		value := strings.Join(values, " ")
-
-		header := Header{
-			Name:  name,
-			Value: value,
-		}
-
-		data.headers = append(data.headers, header)
-
-		// Count
-		// - the length of the header name
-		// - the length of ": "
-		// - the length of the header value
-		// - the length of CRLF
-		data.size += len(name) + 2 + len(value) + 2
-
-		switch name {
-		case "Content-Type":
-			data.mimeType = value
-
-		case "Location":
-			data.location = value
+	// http.Header does not retain the order in which headers were sent,
+	// so the headers are sorted by name to produce a stable order.
+	// Repeated headers are kept in the order they were sent.
+	for _, name := range slices.Sorted(maps.Keys(httpHeader)) {
+		for _, value := range httpHeader[name] {
+			header := Header{
+				Name:  name,
+				Value: value,
+			}
+
+			data.headers = append(data.headers, header)
+
+			// Count
+			// - the length of the header name
+			// - the length of ": "
+			// - the length of the header value
+			// - the length of CRLF
+			data.size += len(name) + 2 + len(value) + 2
 		}
 	}
 
+	data.mimeType = httpHeader.Get("Content-Type")
+	data.location = httpHeader.Get("Location")
+
 	// Count the additional CRLF
 	data.size += 2
 
 	return data
 }
 
+// mimeTypeFromHeaders returns the value of the Content-Type header, if any.
+func mimeTypeFromHeaders(headers []Header) string {
+	return headerValue(headers, "Content-Type")
+}
+
+// headerValue returns the value of the first header with the given name,
+// if any.
+func headerValue(headers []Header, name string) string {
+	for _, header := range headers {
+		if strings.EqualFold(header.Name, name) {
+			return header.Value
+		}
+	}
+	return ""
+}
+
 // peekBody reads all bytes from an io.ReadCloser without "consuming" it,
 // by replacing it with a new io.ReadCloser that can be read again.
 //
@@ -0,0 +1,83 @@
+package har
+
+import (
+	"net/http"
+	"time"
+)
+
+// cookieTimeFormat is the ISO 8601 format used for cookie expiration times.
+const cookieTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// cookiesFromHttpRequest returns the cookies sent in the Cookie headers
+// of req.
+func cookiesFromHttpRequest(req *http.Request) []Cookie {
+	httpCookies := req.Cookies()
+
+	cookies := make([]Cookie, 0, len(httpCookies))
+	for _, httpCookie := range httpCookies {
+		cookies = append(cookies, Cookie{
+			Name:  httpCookie.Name,
+			Value: httpCookie.Value,
+		})
+	}
+
+	return cookies
+}
+
+// cookiesFromHttpResponse returns the cookies set by the Set-Cookie headers
+// of res.
+func cookiesFromHttpResponse(res *http.Response) []Cookie {
+	httpCookies := res.Cookies()
+
+	cookies := make([]Cookie, 0, len(httpCookies))
+	for _, httpCookie := range httpCookies {
+		cookies = append(cookies, Cookie{
+			Name:     httpCookie.Name,
+			Value:    httpCookie.Value,
+			Path:     httpCookie.Path,
+			Domain:   httpCookie.Domain,
+			Expires:  cookieExpires(httpCookie),
+			HTTPOnly: httpCookie.HttpOnly,
+			Secure:   httpCookie.Secure,
+			SameSite: cookieSameSite(httpCookie.SameSite),
+		})
+	}
+
+	return cookies
+}
+
+// cookieExpires returns the expiration time of the cookie in ISO 8601,
+// or an empty string if it is a session cookie.
+// As in browsers, Max-Age takes precedence over Expires.
+func cookieExpires(cookie *http.Cookie) string {
+	switch {
+	case cookie.MaxAge < 0:
+		return time.Unix(0, 0).UTC().Format(cookieTimeFormat)
+
+	case cookie.MaxAge > 0:
+		maxAge := time.Duration(cookie.MaxAge) * time.Second
+		return time.Now().Add(maxAge).UTC().Format(cookieTimeFormat)
+
+	case !cookie.Expires.IsZero():
+		return cookie.Expires.UTC().Format(cookieTimeFormat)
+
+	default:
+		return ""
+	}
+}
+
+func cookieSameSite(sameSite http.SameSite) string {
+	switch sameSite {
+	case http.SameSiteLaxMode:
+		return "Lax"
+
+	case http.SameSiteStrictMode:
+		return "Strict"
+
+	case http.SameSiteNoneMode:
+		return "None"
+
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,151 @@
+package har
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Decoder returns a reader that decodes content that has been encoded with
+// a specific Content-Encoding.
+type Decoder func(r io.Reader) (io.Reader, error)
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = map[string]Decoder{
+		"gzip":    decodeGzip,
+		"x-gzip":  decodeGzip,
+		"deflate": decodeDeflate,
+	}
+)
+
+// RegisterDecoder registers a decoder for a Content-Encoding,
+// replacing any decoder already registered for it.
+//
+// Decoders for gzip and deflate are registered by default.
+// Other encodings, such as brotli ("br") and zstd ("zstd"), can be supported
+// by registering a decoder from a third party package, e.g.:
+//
+//	har.RegisterDecoder("br", func(r io.Reader) (io.Reader, error) {
+//		return brotli.NewReader(r), nil
+//	})
+func RegisterDecoder(contentEncoding string, decoder Decoder) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+
+	decoders[strings.ToLower(contentEncoding)] = decoder
+}
+
+func lookupDecoder(contentEncoding string) (Decoder, bool) {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+
+	decoder, ok := decoders[contentEncoding]
+	return decoder, ok
+}
+
+func decodeGzip(r io.Reader) (io.Reader, error) {
+	return gzip.NewReader(r)
+}
+
+// decodeDeflate decodes the "deflate" Content-Encoding, which is meant to be
+// zlib wrapped, but is sent as raw deflate data by some servers.
+func decodeDeflate(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+
+	// A zlib header starts with the compression method 8 (deflate)
+	// in the lower bits of the first byte.
+	header, err := buffered.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(buffered)
+	}
+
+	return flate.NewReader(buffered), nil
+}
+
+// contentEncodings returns the encodings listed in a Content-Encoding header
+// value, in the order they were applied, leaving out "identity".
+func contentEncodings(contentEncoding string) []string {
+	var encodings []string
+	for _, encoding := range strings.Split(contentEncoding, ",") {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		if encoding != "" && encoding != "identity" {
+			encodings = append(encodings, encoding)
+		}
+	}
+	return encodings
+}
+
+// decodeContent decodes body according to the given encodings,
+// which are listed in the order they were applied.
+//
+// At most limit bytes of decoded content are returned, unless limit is
+// negative, but the entire body is decoded in order to determine the size of
+// the decoded content.
+// If partial is true, the body is expected to end prematurely, and whatever
+// could be decoded is returned.
+//
+// ok is false if an encoding is not supported,
+// or if the body could not be decoded.
+func decodeContent(
+	encodings []string,
+	body []byte,
+	limit int,
+	partial bool,
+) (decoded []byte, size int, ok bool) {
+	var reader io.Reader = bytes.NewReader(body)
+
+	// The encodings are listed in the order they were applied,
+	// so they need to be decoded in reverse order.
+	for i := len(encodings) - 1; i >= 0; i-- {
+		decoder, found := lookupDecoder(encodings[i])
+		if !found {
+			return nil, 0, false
+		}
+
+		var err error
+		reader, err = decoder(reader)
+		if err != nil {
+			return nil, 0, false
+		}
+	}
+
+	var buffer bytes.Buffer
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := reader.Read(chunk)
+
+		recorded := chunk[:n]
+		if limit >= 0 {
+			remaining := limit - buffer.Len()
+			if remaining < n {
+				recorded = recorded[:remaining]
+			}
+		}
+		buffer.Write(recorded)
+		size += n
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if partial {
+				break
+			}
+			return nil, 0, false
+		}
+	}
+
+	decoded = buffer.Bytes()
+	if decoded == nil {
+		decoded = []byte{}
+	}
+
+	return decoded, size, true
+}
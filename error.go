@@ -0,0 +1,89 @@
+package har
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+)
+
+// ResponseFromError creates the response of a request that failed with err
+// before a response was received.
+//
+// This mirrors how Google Chrome exports failed requests:
+// the status is 0, and the error message is stored in the custom "_error"
+// field. Its category is stored in the custom "_errorCategory" field.
+func ResponseFromError(err error) Response {
+	return Response{
+		Status:      0,
+		StatusText:  "",
+		HttpVersion: "",
+		Cookies:     []Cookie{},
+		Headers:     []Header{},
+		Content: Content{
+			Size:     0,
+			MimeType: "x-unknown",
+		},
+		RedirectURL:   "",
+		HeadersSize:   -1,
+		BodySize:      -1,
+		Error:         err.Error(),
+		ErrorCategory: categorizeError(err),
+	}
+}
+
+func categorizeError(err error) ErrorCategory {
+	var dnsError *net.DNSError
+	if errors.As(err, &dnsError) {
+		return ErrorCategoryDNS
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return ErrorCategoryCanceled
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorCategoryTimeout
+	}
+
+	var netError net.Error
+	if errors.As(err, &netError) && netError.Timeout() {
+		return ErrorCategoryTimeout
+	}
+
+	if isTLSError(err) {
+		return ErrorCategoryTLS
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrorCategoryConnectionRefused
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorCategoryConnectionReset
+	}
+
+	return ErrorCategoryOther
+}
+
+func isTLSError(err error) bool {
+	var recordHeaderError tls.RecordHeaderError
+	var alertError tls.AlertError
+	var verificationError *tls.CertificateVerificationError
+	var unknownAuthorityError x509.UnknownAuthorityError
+	var hostnameError x509.HostnameError
+	var certificateInvalidError x509.CertificateInvalidError
+
+	return errors.As(err, &recordHeaderError) ||
+		errors.As(err, &alertError) ||
+		errors.As(err, &verificationError) ||
+		errors.As(err, &unknownAuthorityError) ||
+		errors.As(err, &hostnameError) ||
+		errors.As(err, &certificateInvalidError)
+}
@@ -0,0 +1,77 @@
+package har
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestResponseFromError(t *testing.T) {
+	err := &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}
+
+	res := ResponseFromError(err)
+	if res.Error != err.Error() {
+		t.Errorf("Error = %q, want %q", res.Error, err.Error())
+	}
+	if res.ErrorCategory != ErrorCategoryDNS {
+		t.Errorf("ErrorCategory = %q, want %q", res.ErrorCategory, ErrorCategoryDNS)
+	}
+
+	encoded, marshalErr := json.Marshal(res)
+	if marshalErr != nil {
+		t.Fatal(marshalErr)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields["_error"] != err.Error() {
+		t.Errorf("_error = %v, want %q", fields["_error"], err.Error())
+	}
+	if fields["_errorCategory"] != "dns" {
+		t.Errorf("_errorCategory = %v, want %q", fields["_errorCategory"], "dns")
+	}
+}
+
+func TestDecodeChromeError(t *testing.T) {
+	// A failed request, as exported by Google Chrome.
+	const entry = `{
+		"startedDateTime": "2024-01-01T00:00:00.000Z",
+		"time": 12.5,
+		"request": {"method": "GET", "url": "http://example.invalid/", "httpVersion": "", "cookies": [], "headers": [], "queryString": [], "headersSize": -1, "bodySize": 0},
+		"response": {"status": 0, "statusText": "", "httpVersion": "", "cookies": [], "headers": [], "content": {"size": 0, "mimeType": "x-unknown"}, "redirectURL": "", "headersSize": -1, "bodySize": -1, "_transferSize": 0, "_error": "net::ERR_NAME_NOT_RESOLVED"},
+		"cache": {},
+		"timings": {"blocked": -1, "dns": -1, "ssl": -1, "connect": -1, "send": 0, "wait": 0, "receive": 0}
+	}`
+
+	archive, err := Decode(strings.NewReader(
+		`{"log": {"version": "1.2", "creator": {"name": "WebInspector", "version": "537.36"}, "entries": [` + entry + `]}}`,
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := archive.Log.Entries[0].Response
+	if res.Error != "net::ERR_NAME_NOT_RESOLVED" {
+		t.Errorf("Error = %q, want %q", res.Error, "net::ERR_NAME_NOT_RESOLVED")
+	}
+	if res.ErrorCategory != "" {
+		t.Errorf("ErrorCategory = %q, want none", res.ErrorCategory)
+	}
+
+	var line bytes.Buffer
+	if err := json.Compact(&line, []byte(entry)); err != nil {
+		t.Fatal(err)
+	}
+
+	scanner := NewEntryScanner(&line)
+	if !scanner.Scan() {
+		t.Fatalf("Scan() = false, err = %v", scanner.Err())
+	}
+	if got := scanner.Entry().Response.Error; got != "net::ERR_NAME_NOT_RESOLVED" {
+		t.Errorf("Error = %q, want %q", got, "net::ERR_NAME_NOT_RESOLVED")
+	}
+}
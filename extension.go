@@ -0,0 +1,381 @@
+package har
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Extensions holds the fields of an object that are not part of its struct,
+// by name, as raw JSON.
+//
+// The spec allows custom fields, whose names start with an underscore,
+// and producers like browsers use them extensively, e.g. "_initiator" and
+// "_resourceType" on entries. Every object keeps the fields it does not know
+// about when it is decoded, and writes them back out when it is encoded,
+// so they survive a round trip unchanged.
+//
+// Fields that are part of the struct take precedence over extensions with
+// the same name.
+type Extensions map[string]json.RawMessage
+
+// Get decodes the extension with the given name into value,
+// and reports whether it was present.
+func (x Extensions) Get(name string, value any) (bool, error) {
+	raw, ok := x[name]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, value)
+}
+
+// Set sets the extension with the given name to the JSON encoding of value.
+func (x *Extensions) Set(name string, value any) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	if *x == nil {
+		*x = make(Extensions)
+	}
+	(*x)[name] = raw
+	return nil
+}
+
+// Delete removes the extension with the given name, if any.
+func (x Extensions) Delete(name string) {
+	delete(x, name)
+}
+
+// SetCustom sets the custom field with the given name to the JSON encoding
+// of value. The name must start with an underscore, as required by the spec.
+func (e *Entry) SetCustom(name string, value any) error {
+	if !strings.HasPrefix(name, "_") {
+		return fmt.Errorf("har: custom field %q must start with an underscore", name)
+	}
+	return e.Extensions.Set(name, value)
+}
+
+// Custom decodes the custom field with the given name into value,
+// and reports whether it was present.
+func (e *Entry) Custom(name string, value any) (bool, error) {
+	return e.Extensions.Get(name, value)
+}
+
+func (a HttpArchive) MarshalJSON() ([]byte, error) {
+	type plain HttpArchive
+	return marshalExtended(plain(a), a.Extensions)
+}
+
+func (a *HttpArchive) UnmarshalJSON(data []byte) error {
+	type plain HttpArchive
+	return unmarshalExtended[HttpArchive](data, (*plain)(a), &a.Extensions)
+}
+
+func (l ArchiveLog) MarshalJSON() ([]byte, error) {
+	type plain ArchiveLog
+	return marshalExtended(plain(l), l.Extensions)
+}
+
+func (l *ArchiveLog) UnmarshalJSON(data []byte) error {
+	type plain ArchiveLog
+	return unmarshalExtended[ArchiveLog](data, (*plain)(l), &l.Extensions)
+}
+
+func (c Creator) MarshalJSON() ([]byte, error) {
+	type plain Creator
+	return marshalExtended(plain(c), c.Extensions)
+}
+
+func (c *Creator) UnmarshalJSON(data []byte) error {
+	type plain Creator
+	return unmarshalExtended[Creator](data, (*plain)(c), &c.Extensions)
+}
+
+func (b Browser) MarshalJSON() ([]byte, error) {
+	type plain Browser
+	return marshalExtended(plain(b), b.Extensions)
+}
+
+func (b *Browser) UnmarshalJSON(data []byte) error {
+	type plain Browser
+	return unmarshalExtended[Browser](data, (*plain)(b), &b.Extensions)
+}
+
+func (p Page) MarshalJSON() ([]byte, error) {
+	type plain Page
+	return marshalExtended(plain(p), p.Extensions)
+}
+
+func (p *Page) UnmarshalJSON(data []byte) error {
+	type plain Page
+	return unmarshalExtended[Page](data, (*plain)(p), &p.Extensions)
+}
+
+func (t PageTimings) MarshalJSON() ([]byte, error) {
+	type plain PageTimings
+	return marshalExtended(plain(t), t.Extensions)
+}
+
+func (t *PageTimings) UnmarshalJSON(data []byte) error {
+	type plain PageTimings
+	return unmarshalExtended[PageTimings](data, (*plain)(t), &t.Extensions)
+}
+
+func (e Entry) MarshalJSON() ([]byte, error) {
+	type plain Entry
+	return marshalExtended(plain(e), e.Extensions)
+}
+
+func (e *Entry) UnmarshalJSON(data []byte) error {
+	type plain Entry
+	return unmarshalExtended[Entry](data, (*plain)(e), &e.Extensions)
+}
+
+func (r Request) MarshalJSON() ([]byte, error) {
+	type plain Request
+	return marshalExtended(plain(r), r.Extensions)
+}
+
+func (r *Request) UnmarshalJSON(data []byte) error {
+	type plain Request
+	return unmarshalExtended[Request](data, (*plain)(r), &r.Extensions)
+}
+
+func (r Response) MarshalJSON() ([]byte, error) {
+	type plain Response
+	return marshalExtended(plain(r), r.Extensions)
+}
+
+func (r *Response) UnmarshalJSON(data []byte) error {
+	type plain Response
+	return unmarshalExtended[Response](data, (*plain)(r), &r.Extensions)
+}
+
+func (c Cookie) MarshalJSON() ([]byte, error) {
+	type plain Cookie
+	return marshalExtended(plain(c), c.Extensions)
+}
+
+func (c *Cookie) UnmarshalJSON(data []byte) error {
+	type plain Cookie
+	return unmarshalExtended[Cookie](data, (*plain)(c), &c.Extensions)
+}
+
+func (h Header) MarshalJSON() ([]byte, error) {
+	type plain Header
+	return marshalExtended(plain(h), h.Extensions)
+}
+
+func (h *Header) UnmarshalJSON(data []byte) error {
+	type plain Header
+	return unmarshalExtended[Header](data, (*plain)(h), &h.Extensions)
+}
+
+func (q QueryString) MarshalJSON() ([]byte, error) {
+	type plain QueryString
+	return marshalExtended(plain(q), q.Extensions)
+}
+
+func (q *QueryString) UnmarshalJSON(data []byte) error {
+	type plain QueryString
+	return unmarshalExtended[QueryString](data, (*plain)(q), &q.Extensions)
+}
+
+func (d PostData) MarshalJSON() ([]byte, error) {
+	type plain PostData
+	return marshalExtended(plain(d), d.Extensions)
+}
+
+func (d *PostData) UnmarshalJSON(data []byte) error {
+	type plain PostData
+	return unmarshalExtended[PostData](data, (*plain)(d), &d.Extensions)
+}
+
+func (p Param) MarshalJSON() ([]byte, error) {
+	type plain Param
+	return marshalExtended(plain(p), p.Extensions)
+}
+
+func (p *Param) UnmarshalJSON(data []byte) error {
+	type plain Param
+	return unmarshalExtended[Param](data, (*plain)(p), &p.Extensions)
+}
+
+func (c Content) MarshalJSON() ([]byte, error) {
+	type plain Content
+	return marshalExtended(plain(c), c.Extensions)
+}
+
+func (c *Content) UnmarshalJSON(data []byte) error {
+	type plain Content
+	return unmarshalExtended[Content](data, (*plain)(c), &c.Extensions)
+}
+
+func (c Cache) MarshalJSON() ([]byte, error) {
+	type plain Cache
+	return marshalExtended(plain(c), c.Extensions)
+}
+
+func (c *Cache) UnmarshalJSON(data []byte) error {
+	type plain Cache
+	return unmarshalExtended[Cache](data, (*plain)(c), &c.Extensions)
+}
+
+func (s CacheEntryState) MarshalJSON() ([]byte, error) {
+	type plain CacheEntryState
+	return marshalExtended(plain(s), s.Extensions)
+}
+
+func (s *CacheEntryState) UnmarshalJSON(data []byte) error {
+	type plain CacheEntryState
+	return unmarshalExtended[CacheEntryState](data, (*plain)(s), &s.Extensions)
+}
+
+func (t Timings) MarshalJSON() ([]byte, error) {
+	type plain Timings
+	return marshalExtended(plain(t), t.Extensions)
+}
+
+func (t *Timings) UnmarshalJSON(data []byte) error {
+	type plain Timings
+	return unmarshalExtended[Timings](data, (*plain)(t), &t.Extensions)
+}
+
+// marshalExtended encodes v, which must encode to a JSON object,
+// followed by the extensions whose names are not fields of v.
+// The extensions are written in the order of their names,
+// so that the output is stable.
+func marshalExtended(v any, extensions Extensions) ([]byte, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil || len(extensions) == 0 {
+		return encoded, err
+	}
+
+	known := knownFields(reflect.TypeOf(v))
+
+	var buffer bytes.Buffer
+	buffer.Write(encoded[:len(encoded)-1])
+	empty := len(bytes.TrimSpace(encoded[1:len(encoded)-1])) == 0
+
+	for _, name := range slices.Sorted(maps.Keys(extensions)) {
+		if known[strings.ToLower(name)] {
+			continue
+		}
+
+		encodedName, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+
+		value := extensions[name]
+		if len(value) == 0 {
+			value = json.RawMessage("null")
+		}
+
+		if !empty {
+			buffer.WriteByte(',')
+		}
+		empty = false
+
+		buffer.Write(encodedName)
+		buffer.WriteByte(':')
+		buffer.Write(value)
+	}
+
+	buffer.WriteByte('}')
+
+	return buffer.Bytes(), nil
+}
+
+// unmarshalExtended decodes data into v, and the fields of data that are not
+// fields of v into extensions.
+// v points to a plain version of T without its methods,
+// which is replaced by T in the errors that name it.
+func unmarshalExtended[T any](data []byte, v any, extensions *Extensions) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return renameTypeError(err, reflect.TypeOf(v).Elem(), reflect.TypeFor[T]())
+	}
+
+	// null leaves the object as is.
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	known := knownFields(reflect.TypeOf(v).Elem())
+	maps.DeleteFunc(fields, func(name string, _ json.RawMessage) bool {
+		return known[strings.ToLower(name)]
+	})
+
+	if len(fields) == 0 {
+		fields = nil
+	}
+	*extensions = fields
+
+	return nil
+}
+
+// renameTypeError replaces the plain type with the named type in err,
+// so that type errors name the types of this package rather than the local
+// types that are used to decode them.
+// Errors of nested types have already been renamed by their own decoders.
+func renameTypeError(err error, plain reflect.Type, named reflect.Type) error {
+	var typeError *json.UnmarshalTypeError
+	if !errors.As(err, &typeError) {
+		return err
+	}
+
+	if typeError.Type == plain {
+		typeError.Type = named
+	}
+	if typeError.Struct == plain.Name() {
+		typeError.Struct = named.Name()
+	}
+
+	return err
+}
+
+// knownFieldsCache holds the result of knownFields by type.
+var knownFieldsCache sync.Map
+
+// knownFields returns the lower-cased JSON names of the fields of a struct
+// type.
+func knownFields(t reflect.Type) map[string]bool {
+	if known, ok := knownFieldsCache.Load(t); ok {
+		return known.(map[string]bool)
+	}
+
+	known := make(map[string]bool, t.NumField())
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+
+		// Like encoding/json, names are matched case-insensitively.
+		known[strings.ToLower(name)] = true
+	}
+
+	knownFieldsCache.Store(t, known)
+	return known
+}
@@ -0,0 +1,108 @@
+package har
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// chromeEntry is an entry as exported by Google Chrome, with its custom fields.
+const chromeEntry = `{
+	"_initiator": {"type": "script", "stack": {"callFrames": [{"functionName": "load", "url": "https://example.com/app.js", "lineNumber": 12, "columnNumber": 4}]}},
+	"_priority": "High",
+	"_resourceType": "fetch",
+	"cache": {},
+	"connection": "443",
+	"pageref": "page_1",
+	"request": {
+		"method": "GET",
+		"url": "https://example.com/api?id=1",
+		"httpVersion": "http/2.0",
+		"headers": [{"name": ":authority", "value": "example.com"}],
+		"queryString": [{"name": "id", "value": "1"}],
+		"cookies": [],
+		"headersSize": -1,
+		"bodySize": 0
+	},
+	"response": {
+		"status": 200,
+		"statusText": "",
+		"httpVersion": "http/2.0",
+		"headers": [{"name": "content-type", "value": "application/json"}],
+		"cookies": [{"name": "session", "value": "abc", "path": "/", "httpOnly": true, "secure": true, "_sameSite": "Lax", "_partitioned": false}],
+		"content": {"size": 2, "mimeType": "application/json", "text": "{}"},
+		"redirectURL": "",
+		"headersSize": -1,
+		"bodySize": -1,
+		"_transferSize": 120,
+		"_error": null,
+		"_fetchedViaServiceWorker": false
+	},
+	"serverIPAddress": "93.184.216.34",
+	"startedDateTime": "2024-01-01T00:00:00.123Z",
+	"time": 31.5,
+	"timings": {"blocked": 1.25, "dns": -1, "ssl": -1, "connect": -1, "send": 0.5, "wait": 28.75, "receive": 1, "_blocked_queueing": 0.75, "_workerStart": -1}
+}`
+
+func TestEntryRoundTrip(t *testing.T) {
+	var entry Entry
+	if err := json.Unmarshal([]byte(chromeEntry), &entry); err != nil {
+		t.Fatal(err)
+	}
+
+	var initiator struct {
+		Type string `json:"type"`
+	}
+	ok, err := entry.Custom("_initiator", &initiator)
+	if err != nil || !ok || initiator.Type != "script" {
+		t.Errorf("Custom(_initiator) = %v, %v, %+v", ok, err, initiator)
+	}
+
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// "_error": null is the only field that does not survive, since it is
+	// the zero value of a known field.
+	want := strings.Replace(chromeEntry, `"_error": null,`, "", 1)
+	assertSameJSON(t, encoded, []byte(want))
+}
+
+func TestUnmarshalTypeErrorNamesType(t *testing.T) {
+	var entry Entry
+	err := json.Unmarshal([]byte(`{"response": {"status": "200"}}`), &entry)
+	if err == nil {
+		t.Fatal("Unmarshal() = nil, want error")
+	}
+
+	message := err.Error()
+	if strings.Contains(message, "plain") || !strings.Contains(message, "Response.status") {
+		t.Errorf("Unmarshal() = %q, want an error that names Response.status", message)
+	}
+
+	err = json.Unmarshal([]byte(`[]`), &entry)
+	if err == nil || !strings.Contains(err.Error(), "har.Entry") {
+		t.Errorf("Unmarshal() = %v, want an error that names har.Entry", err)
+	}
+}
+
+func assertSameJSON(t *testing.T, got []byte, want []byte) {
+	t.Helper()
+
+	var gotValue, wantValue any
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(want, &wantValue); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		var indented bytes.Buffer
+		_ = json.Indent(&indented, got, "", "  ")
+		t.Errorf("got:\n%s\nwant:\n%s", indented.String(), want)
+	}
+}
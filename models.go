@@ -4,6 +4,8 @@ import "time"
 
 type HttpArchive struct {
 	Log ArchiveLog `json:"log"`
+
+	Extensions Extensions `json:"-"`
 }
 
 /*
@@ -15,7 +17,7 @@ type HttpArchive struct {
  * While the struct model the entire spec, there are various pieces of data
  * that we currently do not fill out, due to limitations on what we're able to
  * observe using standard HTTP tooling in Go - for example, we don't provide
- * a granular breakdown of request timings or resolved server IP addresses.
+ * any cache information.
  *
  * This means that the produced HAR file is not as detailed as one might be used
  * to, e.g. when inspecting network requests in Google Chrome.
@@ -33,9 +35,9 @@ type ArchiveLog struct {
 	// Name and version info of used browser.
 	Browser *Browser `json:"browser,omitempty"`
 
-	// Page is a list of all exported (tracked) pages.
+	// Pages is a list of all exported (tracked) pages.
 	// Leave out this field if the application does not support grouping by pages.
-	Page []Page `json:"page,omitempty"`
+	Pages []Page `json:"pages,omitempty"`
 
 	// Entries is a list of all exported (tracked) requests.
 	// Sorting entries by startedDateTime (starting from the oldest) is the
@@ -46,6 +48,8 @@ type ArchiveLog struct {
 
 	// Comment is a comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+
+	Extensions Extensions `json:"-"`
 }
 
 // Name and version info of the log creator application.
@@ -58,6 +62,8 @@ type Creator struct {
 
 	// Comment is a comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+
+	Extensions Extensions `json:"-"`
 }
 
 // Name and version info of used browser.
@@ -70,6 +76,8 @@ type Browser struct {
 
 	// Comment is a comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+
+	Extensions Extensions `json:"-"`
 }
 
 // This object represents an exported page.
@@ -91,21 +99,25 @@ type Page struct {
 
 	// Comment is a comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+
+	Extensions Extensions `json:"-"`
 }
 
 type PageTimings struct {
 	// OnContentLoad is the number of milliseconds since page load started
 	// (page.startedDateTime).
 	// Use -1 if the timing does not apply to the current request.
-	OnContentLoad int `json:"onContentLoad,omitempty"`
+	OnContentLoad float64 `json:"onContentLoad"`
 
 	// OnLoad is when the page is loaded (onLoad event fired).
 	// Number of milliseconds since page load started (page.startedDateTime).
 	// Use -1 if the timing does not apply to the current request.
-	OnLoad int `json:"onLoad,omitempty"`
+	OnLoad float64 `json:"onLoad"`
 
 	// Comment is a comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+
+	Extensions Extensions `json:"-"`
 }
 
 // This object represents an exported HTTP request.
@@ -122,7 +134,7 @@ type Entry struct {
 	// Time is the total elapsed time of the request in milliseconds.
 	// This is the sum of all timings available in the timings object
 	// (i.e. not including -1 values).
-	Time int `json:"time"`
+	Time float64 `json:"time"`
 
 	// Request has detailed info about the request.
 	Request Request `json:"request"`
@@ -151,6 +163,8 @@ type Entry struct {
 
 	// Comment is a comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+
+	Extensions Extensions `json:"-"`
 }
 
 // This object contains detailed info about the performed request.
@@ -187,6 +201,8 @@ type Request struct {
 
 	// Comment is a comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+
+	Extensions Extensions `json:"-"`
 }
 
 // This object contains detailed info about the response.
@@ -229,8 +245,34 @@ type Response struct {
 
 	// Comment is a comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+
+	// Error is the error message of a request that failed before a response
+	// was received, e.g. "net::ERR_NAME_NOT_RESOLVED" in HAR files exported
+	// by Google Chrome.
+	// This is a custom field, since the spec has no notion of failed requests.
+	Error string `json:"_error,omitempty"`
+
+	// ErrorCategory is a coarse classification of Error, if known.
+	// This is a custom field, which is not part of the HAR files exported by
+	// browsers.
+	ErrorCategory ErrorCategory `json:"_errorCategory,omitempty"`
+
+	Extensions Extensions `json:"-"`
 }
 
+// ErrorCategory is a coarse classification of why a request failed.
+type ErrorCategory string
+
+const (
+	ErrorCategoryDNS               ErrorCategory = "dns"
+	ErrorCategoryTimeout           ErrorCategory = "timeout"
+	ErrorCategoryCanceled          ErrorCategory = "canceled"
+	ErrorCategoryConnectionRefused ErrorCategory = "connection_refused"
+	ErrorCategoryConnectionReset   ErrorCategory = "connection_reset"
+	ErrorCategoryTLS               ErrorCategory = "tls"
+	ErrorCategoryOther             ErrorCategory = "other"
+)
+
 // This object contains list of all cookies
 // (used in Request and Response objects).
 type Cookie struct {
@@ -241,7 +283,7 @@ type Cookie struct {
 	Value string `json:"value"`
 
 	// The path pertaining to the cookie.
-	Path string `json:"path"`
+	Path string `json:"path,omitempty"`
 
 	// The host of the cookie.
 	Domain string `json:"domain,omitempty"`
@@ -259,22 +301,31 @@ type Cookie struct {
 
 	// Comment is a comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+
+	// SameSite is the SameSite attribute of the cookie
+	// ("Strict", "Lax" or "None"), if any.
+	// This is a custom field.
+	SameSite string `json:"_sameSite,omitempty"`
+
+	Extensions Extensions `json:"-"`
 }
 
 // This object contains details of a header
 // (used in Request and Response objects).
 type Header struct {
-	Name    string `json:"name"`
-	Value   string `json:"value"`
-	Comment string `json:"comment,omitempty"`
+	Name       string     `json:"name"`
+	Value      string     `json:"value"`
+	Comment    string     `json:"comment,omitempty"`
+	Extensions Extensions `json:"-"`
 }
 
 // This object contains describes a value parsed from a query string,
 // (embedded in Request object).
 type QueryString struct {
-	Name    string `json:"name"`
-	Value   string `json:"value"`
-	Comment string `json:"comment,omitempty"`
+	Name       string     `json:"name"`
+	Value      string     `json:"value"`
+	Comment    string     `json:"comment,omitempty"`
+	Extensions Extensions `json:"-"`
 }
 
 // This object describes posted data, if any
@@ -293,6 +344,18 @@ type PostData struct {
 
 	// Comment is a comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+
+	// Truncated is true if the text only holds the first part of the posted
+	// data. This is a custom field.
+	Truncated bool `json:"_truncated,omitempty"`
+
+	// Encoding used for the text field e.g "base64",
+	// in the same way as for the response content.
+	// Leave out this field if the text field holds the posted data as is.
+	// This is a custom field.
+	Encoding string `json:"_encoding,omitempty"`
+
+	Extensions Extensions `json:"-"`
 }
 
 // List of posted parameters, if any
@@ -312,6 +375,8 @@ type Param struct {
 
 	// Comment is a comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+
+	Extensions Extensions `json:"-"`
 }
 
 // This object describes details about response content
@@ -324,7 +389,7 @@ type Content struct {
 
 	// Number of bytes saved.
 	// Leave out this field if the information is not available.
-	Compression int `json:"compression"`
+	Compression int `json:"compression,omitempty"`
 
 	// MIME type of the response text
 	// (value of the Content-Type response header).
@@ -336,7 +401,7 @@ type Content struct {
 	// The text field is either HTTP decoded text or a encoded
 	// (e.g. "base64") representation of the response body.
 	// Leave out this field if the information is not available.
-	Text string `json:"text"`
+	Text string `json:"text,omitempty"`
 
 	// Encoding used for response text field e.g "base64".
 	// Leave out this field if the text field is HTTP decoded
@@ -346,26 +411,35 @@ type Content struct {
 
 	// Comment is a comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+
+	// Truncated is true if the text only holds the first part of the response
+	// body, in which case size still holds the length of the entire body.
+	// This is a custom field.
+	Truncated bool `json:"_truncated,omitempty"`
+
+	Extensions Extensions `json:"-"`
 }
 
 // This objects contains info about a request coming from browser cache.
 type Cache struct {
 	// BeforeRequest is the state of a cache entry before the request.
 	// Leave out this field if the information is not available.
-	BeforeRequest CacheEntryState `json:"beforeRequest,omitempty"`
+	BeforeRequest *CacheEntryState `json:"beforeRequest,omitempty"`
 
 	// AfterRequest is the state of a cache entry after the request.
 	// Leave out this field if the information is not available.
-	AfterRequest CacheEntryState `json:"afterRequest,omitempty"`
+	AfterRequest *CacheEntryState `json:"afterRequest,omitempty"`
 
 	// Comment is a comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+
+	Extensions Extensions `json:"-"`
 }
 
 // CacheEntryState contains information about a cache entry.
 type CacheEntryState struct {
 	// Expires is the expiration time of the cache entry.
-	Expires string `json:"expires"`
+	Expires string `json:"expires,omitempty"`
 
 	// LastAccess is the last time the cache entry was opened.
 	LastAccess string `json:"lastAccess"`
@@ -378,6 +452,8 @@ type CacheEntryState struct {
 
 	// Comment is a comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+
+	Extensions Extensions `json:"-"`
 }
 
 // Timings describes various phases within request-response round trip.
@@ -388,32 +464,54 @@ type CacheEntryState struct {
 type Timings struct {
 	// Blocked is the time spent in a queue waiting for a network connection.
 	// Use -1 if the timing does not apply to the current request.
-	Blocked int `json:"blocked"`
+	Blocked float64 `json:"blocked"`
 
 	// DNS is the DNS resolution time. The time required to resolve a host name.
 	// Use -1 if the timing does not apply to the current request.
-	DNS int `json:"dns"`
+	DNS float64 `json:"dns"`
 
 	// Connect is the time required to create the TCP connection.
 	// Use -1 if the timing does not apply to the current request.
-	Connect int `json:"connect"`
+	Connect float64 `json:"connect"`
 
 	// Send is the time required to send HTTP request to the server.
-	Send int `json:"send"`
+	Send float64 `json:"send"`
 
 	// Wait is the time spent waiting for a response from the server.
-	Wait int `json:"wait"`
+	Wait float64 `json:"wait"`
 
 	// Receive is the time required to read the entire response from the server
 	// (or cache).
-	Receive int `json:"receive"`
+	Receive float64 `json:"receive"`
 
 	// SSL is the time required for SSL/TLS negotiation.
 	// If this field is defined then the time is also included in the connect
 	// field (to ensure backward compatibility with HAR 1.1).
 	// Use -1 if the timing does not apply to the current request.
-	SSL int `json:"ssl"`
+	SSL float64 `json:"ssl"`
 
 	// Comment is a comment provided by the user or the application.
 	Comment string `json:"comment,omitempty"`
+
+	Extensions Extensions `json:"-"`
+}
+
+// Total returns the sum of all timings, not including -1 values.
+// This is the value that should be used for the time of an Entry.
+func (t Timings) Total() float64 {
+	total := 0.0
+	for _, timing := range []float64{
+		t.Blocked,
+		t.DNS,
+		t.Connect,
+		t.Send,
+		t.Wait,
+		t.Receive,
+	} {
+		if timing > 0 {
+			total += timing
+		}
+	}
+	// SSL is not added, since it is already included in Connect.
+	return total
 }
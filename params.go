@@ -0,0 +1,82 @@
+package har
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"unicode/utf8"
+)
+
+// paramsFromBody parses the parameters of a posted form.
+// It returns nil if the body is not a form.
+//
+// If the body has been truncated, the parameters that could be parsed before
+// the point of truncation are returned.
+func paramsFromBody(mimeType string, body []byte) []Param {
+	mediaType, mediaParams, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return nil
+	}
+
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		return paramsFromURLEncoded(body)
+
+	case "multipart/form-data":
+		return paramsFromMultipart(body, mediaParams["boundary"])
+
+	default:
+		return nil
+	}
+}
+
+func paramsFromURLEncoded(body []byte) []Param {
+	params := []Param{}
+
+	parseURLEncoded(string(body), func(name string, value string) {
+		params = append(params, Param{
+			Name:  name,
+			Value: value,
+		})
+	})
+
+	return params
+}
+
+func paramsFromMultipart(body []byte, boundary string) []Param {
+	if boundary == "" {
+		return nil
+	}
+
+	params := []Param{}
+
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			// Either we are done, or the body is malformed or truncated,
+			// in which case we keep what we have.
+			return params
+		}
+
+		content, err := io.ReadAll(part)
+		if err != nil {
+			return params
+		}
+
+		param := Param{
+			Name:        part.FormName(),
+			FileName:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+		}
+
+		// The value is a plain string, so binary file contents are left out.
+		// They are still available from the text of the posted data.
+		if utf8.Valid(content) {
+			param.Value = string(content)
+		}
+
+		params = append(params, param)
+	}
+}
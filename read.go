@@ -0,0 +1,334 @@
+package har
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// Decode decodes a complete HAR document from r.
+//
+// The entire document is held in memory. Use an EntryScanner to process
+// large documents one entry at a time.
+func Decode(r io.Reader) (*HttpArchive, error) {
+	var archive HttpArchive
+	if err := json.NewDecoder(r).Decode(&archive); err != nil {
+		return nil, err
+	}
+	return &archive, nil
+}
+
+// EntryScanner reads entries one at a time, from either a HAR document or an
+// entry file with one JSON encoded entry per line, as written by harwriter.
+// The format is detected from the first key of the input.
+//
+// Only a single entry is held in memory at a time, so inputs of any size can
+// be processed.
+//
+// Scanning stops at the first error, which is returned by Err.
+// In entry files, blank lines are skipped, as is an incomplete last line,
+// which is what a process that crashed while writing leaves behind.
+// Errors on a specific line of an entry file are reported as a *LineError.
+type EntryScanner struct {
+	reader *bufio.Reader
+	mode   scanMode
+
+	// decoder and inEntries keep track of where we are in a HAR document.
+	decoder   *json.Decoder
+	inEntries bool
+
+	// line is the number of the last line read from an entry file.
+	line int
+
+	entry Entry
+	pages []Page
+	err   error
+	done  bool
+}
+
+type scanMode int
+
+const (
+	scanUnknown scanMode = iota
+	scanArchive
+	scanLines
+)
+
+// NewEntryScanner returns a scanner that reads entries from r.
+func NewEntryScanner(r io.Reader) *EntryScanner {
+	return &EntryScanner{
+		reader: bufio.NewReader(r),
+	}
+}
+
+// Scan advances the scanner to the next entry, which is then available
+// through Entry. It returns false when there are no more entries, either
+// because the end of the input was reached or because an error occurred.
+func (s *EntryScanner) Scan() bool {
+	if s.done {
+		return false
+	}
+
+	var ok bool
+	if s.mode == scanUnknown {
+		s.err = s.detect()
+	}
+
+	if s.err == nil {
+		switch s.mode {
+		case scanArchive:
+			ok, s.err = s.scanArchive()
+
+		case scanLines:
+			ok, s.err = s.scanLines()
+		}
+	}
+
+	if !ok {
+		s.done = true
+		s.entry = Entry{}
+	}
+
+	return ok
+}
+
+// Entry returns the entry that was read by the last call to Scan.
+func (s *EntryScanner) Entry() Entry {
+	return s.entry
+}
+
+// Pages returns the pages encountered so far.
+//
+// Pages can appear both before and after the entries of a HAR document,
+// and anywhere in an entry file, so all pages are only known once Scan has
+// returned false.
+func (s *EntryScanner) Pages() []Page {
+	return slices.Clone(s.pages)
+}
+
+// Err returns the first error that was encountered, if any.
+func (s *EntryScanner) Err() error {
+	return s.err
+}
+
+// detect determines the format of the input by looking at its first key.
+// A HAR document has a single "log" key, which is never the key of an entry.
+// Anything else is read as an entry file, which reports errors by line.
+func (s *EntryScanner) detect() error {
+	recorder := &recordingReader{reader: s.reader, recording: true}
+	decoder := json.NewDecoder(recorder)
+
+	if isArchive(decoder) {
+		if err := expectDelim(decoder, '{'); err != nil {
+			return err
+		}
+
+		s.mode = scanArchive
+		s.decoder = decoder
+		recorder.stop()
+		return nil
+	}
+
+	// Read the entry file from the start, including what was consumed while
+	// detecting the format.
+	s.mode = scanLines
+	s.reader = bufio.NewReader(io.MultiReader(
+		bytes.NewReader(recorder.recorded),
+		s.reader,
+	))
+	return nil
+}
+
+// isArchive reports whether the first key of the input is "log".
+func isArchive(decoder *json.Decoder) bool {
+	if err := expectDelim(decoder, '{'); err != nil {
+		return false
+	}
+
+	key, err := decoder.Token()
+	return err == nil && key == "log"
+}
+
+// scanArchive reads the next entry of the log of a HAR document,
+// collecting pages and skipping any other fields along the way.
+func (s *EntryScanner) scanArchive() (bool, error) {
+	decoder := s.decoder
+
+	for {
+		if s.inEntries {
+			if decoder.More() {
+				var entry Entry
+				if err := decoder.Decode(&entry); err != nil {
+					return false, err
+				}
+				s.entry = entry
+				return true, nil
+			}
+
+			if err := expectDelim(decoder, ']'); err != nil {
+				return false, err
+			}
+			s.inEntries = false
+			continue
+		}
+
+		// The end of the log is the end of the entries, so the rest of the
+		// document is not read.
+		if !decoder.More() {
+			return false, expectDelim(decoder, '}')
+		}
+
+		key, err := decoder.Token()
+		if err != nil {
+			return false, err
+		}
+
+		switch key {
+		case "entries":
+			token, err := decoder.Token()
+			if err != nil {
+				return false, err
+			}
+			switch token {
+			case json.Delim('['):
+				s.inEntries = true
+			case nil:
+			default:
+				return false, fmt.Errorf("har: expected entries to be an array, got %v", token)
+			}
+
+		case "pages":
+			var pages []Page
+			if err := decoder.Decode(&pages); err != nil {
+				return false, err
+			}
+			s.pages = append(s.pages, pages...)
+
+		default:
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return false, err
+			}
+		}
+	}
+}
+
+// scanLines reads the next entry of an entry file, collecting the pages
+// along the way. Pages are told apart from entries by their page timings.
+func (s *EntryScanner) scanLines() (bool, error) {
+	for {
+		line, readErr := s.reader.ReadBytes('\n')
+		s.line++
+
+		// A missing line ending means that this is the last line,
+		// and that it may have been cut short.
+		complete := len(line) > 0 && line[len(line)-1] == '\n'
+
+		// A compressed stream that ends prematurely is treated like the end
+		// of the input, since it is cut short the same way.
+		if readErr != nil &&
+			!errors.Is(readErr, io.EOF) &&
+			!errors.Is(readErr, io.ErrUnexpectedEOF) {
+			return false, &LineError{Line: s.line, Err: readErr}
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			entry, page, err := decodeLine(line)
+			switch {
+			case err != nil && complete:
+				return false, &LineError{Line: s.line, Err: err}
+
+			case err == nil && page != nil:
+				s.pages = append(s.pages, *page)
+
+			case err == nil:
+				s.entry = entry
+				return true, nil
+			}
+		}
+
+		if readErr != nil {
+			return false, nil
+		}
+	}
+}
+
+// decodeLine decodes a line of an entry file,
+// which holds either an entry or a page.
+func decodeLine(line []byte) (Entry, *Page, error) {
+	var probe struct {
+		PageTimings *json.RawMessage `json:"pageTimings"`
+	}
+	if err := json.Unmarshal(line, &probe); err != nil {
+		return Entry{}, nil, err
+	}
+
+	if probe.PageTimings != nil {
+		var page Page
+		if err := json.Unmarshal(line, &page); err != nil {
+			return Entry{}, nil, err
+		}
+		return Entry{}, &page, nil
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return Entry{}, nil, err
+	}
+	return entry, nil, nil
+}
+
+// LineError describes a line of an entry file that could not be read.
+type LineError struct {
+	// Line is the number of the line, starting at 1.
+	Line int
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("har: line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// expectDelim reads the next token, which must be the given delimiter.
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("har: expected %v, got %v", delim, token)
+	}
+	return nil
+}
+
+// recordingReader keeps a copy of everything that is read until it is
+// stopped, so that it can be read again.
+type recordingReader struct {
+	reader    io.Reader
+	recorded  []byte
+	recording bool
+}
+
+func (r *recordingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if r.recording {
+		r.recorded = append(r.recorded, p[:n]...)
+	}
+	return n, err
+}
+
+// stop stops recording and releases what has been recorded.
+func (r *recordingReader) stop() {
+	r.recording = false
+	r.recorded = nil
+}
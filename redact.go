@@ -0,0 +1,789 @@
+package har
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// RedactAction is what is done with a sensitive value.
+type RedactAction int
+
+const (
+	// RedactMask replaces the value with RedactedValue.
+	RedactMask RedactAction = iota
+
+	// RedactHash replaces the value with a SHA-256 hash of it,
+	// so that equal values can still be told apart from different ones.
+	// See WithHashKey for keyed hashing.
+	RedactHash
+
+	// RedactRemove removes the header, cookie, parameter or field
+	// altogether.
+	RedactRemove
+)
+
+// RedactedValue is the value that masked values are replaced with.
+const RedactedValue = "REDACTED"
+
+// Redactor removes sensitive data from entries, such as credentials in
+// headers, session cookies, and tokens in query strings and bodies.
+//
+// Names of headers, cookies and query parameters are matched
+// case-insensitively. If more than one rule matches a value,
+// the last one given decides what is done with it.
+//
+// A Redactor is safe for concurrent use by multiple goroutines.
+type Redactor struct {
+	headers []nameRule
+	cookies []nameRule
+	query   []nameRule
+	fields  []fieldRule
+	hashKey []byte
+}
+
+type nameRule struct {
+	match  func(name string) bool
+	action RedactAction
+}
+
+type fieldRule struct {
+	path   fieldPath
+	action RedactAction
+}
+
+// RedactOption configures a Redactor.
+type RedactOption func(*Redactor) error
+
+// NewRedactor returns a Redactor with the given rules.
+// Without any rules, entries are left as they are. See DefaultRedaction for
+// a set of rules that covers common secrets.
+func NewRedactor(opts ...RedactOption) (*Redactor, error) {
+	var redactor Redactor
+	if err := redactor.apply(opts...); err != nil {
+		return nil, err
+	}
+	return &redactor, nil
+}
+
+var (
+	defaultRedactedHeaders = []string{
+		"Authorization",
+		"Proxy-Authorization",
+		"X-Api-Key",
+		"X-Auth-Token",
+		"X-Access-Token",
+		"X-Csrf-Token",
+		"X-Xsrf-Token",
+		"X-Amz-Security-Token",
+	}
+
+	defaultRedactedCookies = regexp.MustCompile(
+		`(?i)sess|sid|auth|token|csrf|xsrf|jwt|secret|remember`,
+	)
+
+	defaultRedactedQueryParams = []string{
+		"access_token",
+		"id_token",
+		"refresh_token",
+		"token",
+		"api_key",
+		"apikey",
+		"password",
+		"secret",
+		"client_secret",
+		"code",
+		"signature",
+		"sig",
+		"X-Amz-Credential",
+		"X-Amz-Security-Token",
+		"X-Amz-Signature",
+	}
+
+	defaultRedactedFields = []string{
+		"$..password",
+		"$..passwd",
+		"$..secret",
+		"$..client_secret",
+		"$..token",
+		"$..access_token",
+		"$..refresh_token",
+		"$..id_token",
+		"$..api_key",
+		"$..apiKey",
+	}
+)
+
+// DefaultRedaction redacts common secrets with the given action:
+// credentials in headers such as Authorization, cookies that look like
+// session or authentication cookies, tokens, keys and signatures in query
+// strings, and passwords and tokens in bodies.
+func DefaultRedaction(action RedactAction) RedactOption {
+	return func(r *Redactor) error {
+		return r.apply(
+			RedactHeaders(action, defaultRedactedHeaders...),
+			RedactCookiesMatching(action, defaultRedactedCookies),
+			RedactQueryParams(action, defaultRedactedQueryParams...),
+			RedactBodyFields(action, defaultRedactedFields...),
+		)
+	}
+}
+
+// RedactHeaders redacts the request and response headers with the given
+// names.
+func RedactHeaders(action RedactAction, names ...string) RedactOption {
+	return func(r *Redactor) error {
+		r.headers = append(r.headers, nameRule{match: matchNames(names), action: action})
+		return nil
+	}
+}
+
+// RedactHeadersMatching redacts the request and response headers whose
+// names match the pattern.
+func RedactHeadersMatching(action RedactAction, pattern *regexp.Regexp) RedactOption {
+	return func(r *Redactor) error {
+		r.headers = append(r.headers, nameRule{match: pattern.MatchString, action: action})
+		return nil
+	}
+}
+
+// RedactCookies redacts the cookies with the given names,
+// both in the cookies of an entry and in its Cookie and Set-Cookie headers.
+func RedactCookies(action RedactAction, names ...string) RedactOption {
+	return func(r *Redactor) error {
+		r.cookies = append(r.cookies, nameRule{match: matchNames(names), action: action})
+		return nil
+	}
+}
+
+// RedactCookiesMatching redacts the cookies whose names match the pattern,
+// both in the cookies of an entry and in its Cookie and Set-Cookie headers.
+func RedactCookiesMatching(action RedactAction, pattern *regexp.Regexp) RedactOption {
+	return func(r *Redactor) error {
+		r.cookies = append(r.cookies, nameRule{match: pattern.MatchString, action: action})
+		return nil
+	}
+}
+
+// RedactQueryParams redacts the query parameters with the given names,
+// both in the query string of an entry and in the URLs it holds,
+// i.e. the request URL, the redirect URL and the Location header.
+func RedactQueryParams(action RedactAction, names ...string) RedactOption {
+	return func(r *Redactor) error {
+		r.query = append(r.query, nameRule{match: matchNames(names), action: action})
+		return nil
+	}
+}
+
+// RedactQueryParamsMatching redacts the query parameters whose names match
+// the pattern. See RedactQueryParams.
+func RedactQueryParamsMatching(action RedactAction, pattern *regexp.Regexp) RedactOption {
+	return func(r *Redactor) error {
+		r.query = append(r.query, nameRule{match: pattern.MatchString, action: action})
+		return nil
+	}
+}
+
+// RedactBodyFields redacts the fields at the given paths in JSON bodies,
+// and the parameters of form bodies that paths of a single field refer to.
+//
+// Paths use a subset of the JSONPath syntax:
+//
+//	$.user.password     the password field of the user object
+//	$.users[*].token    the token field of every element of the users array
+//	$.users[0]          the first element of the users array
+//	$..password         every password field, at any depth
+//
+// Base64 encoded bodies are decoded to be redacted.
+// Bodies that cannot be parsed, e.g. because they are truncated, have their
+// text removed, which is noted in their comment, since the fields might be
+// in there. The same goes for truncated multipart bodies, and multipart
+// bodies with redacted parameters, since their text cannot be redacted
+// selectively.
+func RedactBodyFields(action RedactAction, paths ...string) RedactOption {
+	return func(r *Redactor) error {
+		for _, path := range paths {
+			parsed, err := parseFieldPath(path)
+			if err != nil {
+				return err
+			}
+			r.fields = append(r.fields, fieldRule{path: parsed, action: action})
+		}
+		return nil
+	}
+}
+
+// WithHashKey makes RedactHash use HMAC-SHA256 with the given key,
+// which keeps values with little entropy, like passwords, from being
+// recovered by hashing candidate values.
+func WithHashKey(key []byte) RedactOption {
+	return func(r *Redactor) error {
+		r.hashKey = key
+		return nil
+	}
+}
+
+func (r *Redactor) apply(opts ...RedactOption) error {
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func matchNames(names []string) func(string) bool {
+	return func(name string) bool {
+		for _, candidate := range names {
+			if strings.EqualFold(candidate, name) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Redact returns a copy of the entry with the sensitive data redacted.
+// The given entry is not modified.
+func (r *Redactor) Redact(entry Entry) Entry {
+	entry.Request = r.redactRequest(entry.Request)
+	entry.Response = r.redactResponse(entry.Response)
+	return entry
+}
+
+func (r *Redactor) redactRequest(request Request) Request {
+	request.URL = r.redactURL(request.URL)
+	request.Headers = r.redactHeaders(request.Headers)
+	request.Cookies = redactNamed(r, r.cookies, request.Cookies, cookieField)
+	request.QueryString = redactNamed(r, r.query, request.QueryString, queryStringField)
+
+	if request.PostData != nil {
+		postData := r.redactPostData(*request.PostData)
+		request.PostData = &postData
+	}
+
+	return request
+}
+
+func (r *Redactor) redactResponse(response Response) Response {
+	response.RedirectURL = r.redactURL(response.RedirectURL)
+	response.Headers = r.redactHeaders(response.Headers)
+	response.Cookies = redactNamed(r, r.cookies, response.Cookies, cookieField)
+
+	if len(r.fields) > 0 && isJSONMimeType(response.Content.MimeType) {
+		text, ok := redactBodyText(response.Content.Text, response.Content.Encoding, r.redactJSON)
+		if !ok {
+			text = ""
+			response.Content.Encoding = ""
+			response.Content.Comment = appendComment(response.Content.Comment, unredactableComment)
+		}
+		response.Content.Text = text
+	}
+
+	return response
+}
+
+func (r *Redactor) redactPostData(postData PostData) PostData {
+	if len(r.fields) == 0 {
+		return postData
+	}
+
+	var (
+		text = postData.Text
+		ok   = true
+	)
+
+	mediaType, _, _ := mime.ParseMediaType(postData.MimeType)
+	switch {
+	case isJSONMimeType(postData.MimeType):
+		text, ok = redactBodyText(postData.Text, postData.Encoding, r.redactJSON)
+
+	case mediaType == "application/x-www-form-urlencoded":
+		rules := r.formRules()
+		postData.Params = redactNamed(r, rules, postData.Params, paramField)
+		text, ok = redactBodyText(postData.Text, postData.Encoding, func(text string) (string, bool) {
+			return r.redactURLEncoded(text, rules), true
+		})
+
+	case mediaType == "multipart/form-data":
+		params := redactNamed(r, r.formRules(), postData.Params, paramField)
+		if !slices.EqualFunc(params, postData.Params, func(a, b Param) bool {
+			return a.Name == b.Name && a.Value == b.Value
+		}) {
+			text = ""
+		}
+		postData.Params = params
+
+		// The parameters of a truncated body may be missing fields that are
+		// still in its text.
+		ok = !postData.Truncated
+	}
+
+	if !ok {
+		text = ""
+		postData.Encoding = ""
+		postData.Comment = appendComment(postData.Comment, unredactableComment)
+	}
+	postData.Text = text
+
+	return postData
+}
+
+// unredactableComment is the comment on bodies whose text was removed,
+// because the fields that are to be redacted could not be found in it.
+const unredactableComment = "the text was removed, since it could not be parsed to redact its fields"
+
+// redactBodyText redacts the text of a body with redact, first decoding it
+// if it has been base64 encoded, and reports whether it could be redacted.
+// Bodies that could not be redacted must not be recorded, since they might
+// hold the fields that were meant to be redacted.
+func redactBodyText(
+	text string,
+	encoding string,
+	redact func(text string) (string, bool),
+) (string, bool) {
+	switch encoding {
+	case "":
+		return redact(text)
+
+	case "base64":
+		decoded, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return "", false
+		}
+
+		redacted, ok := redact(string(decoded))
+		if !ok {
+			return "", false
+		}
+		if redacted == string(decoded) {
+			return text, true
+		}
+		return base64.StdEncoding.EncodeToString([]byte(redacted)), true
+
+	default:
+		return "", false
+	}
+}
+
+// appendComment adds a comment to an existing one.
+func appendComment(comment string, addition string) string {
+	if comment == "" {
+		return addition
+	}
+	return comment + "; " + addition
+}
+
+// formRules returns the field rules as rules for the names of form
+// parameters.
+func (r *Redactor) formRules() []nameRule {
+	rules := make([]nameRule, 0, len(r.fields))
+	for _, rule := range r.fields {
+		rules = append(rules, nameRule{
+			match: func(name string) bool {
+				return rule.path.matches([]string{name})
+			},
+			action: rule.action,
+		})
+	}
+	return rules
+}
+
+// redactHeaders redacts headers by name, and the cookies and URLs within
+// the values of the remaining headers.
+func (r *Redactor) redactHeaders(headers []Header) []Header {
+	headers = redactNamed(r, r.headers, headers, headerField)
+	if headers == nil {
+		return nil
+	}
+
+	redacted := make([]Header, 0, len(headers))
+	for _, header := range headers {
+		keep := true
+		switch {
+		case strings.EqualFold(header.Name, "Cookie"):
+			header.Value = r.redactCookieHeader(header.Value)
+			keep = header.Value != ""
+
+		case strings.EqualFold(header.Name, "Set-Cookie"):
+			header.Value, keep = r.redactSetCookieHeader(header.Value)
+
+		case strings.EqualFold(header.Name, "Location"):
+			header.Value = r.redactURL(header.Value)
+		}
+
+		if keep {
+			redacted = append(redacted, header)
+		}
+	}
+	return redacted
+}
+
+// redactCookieHeader redacts the cookies in the value of a Cookie header.
+func (r *Redactor) redactCookieHeader(value string) string {
+	if len(r.cookies) == 0 {
+		return value
+	}
+
+	var pairs []string
+	for _, pair := range strings.Split(value, ";") {
+		pair = strings.TrimSpace(pair)
+		name, cookieValue, _ := strings.Cut(pair, "=")
+
+		if action, ok := r.match(r.cookies, name); ok {
+			if action == RedactRemove {
+				continue
+			}
+			pair = name + "=" + r.redactString(cookieValue, action)
+		}
+		pairs = append(pairs, pair)
+	}
+
+	return strings.Join(pairs, "; ")
+}
+
+// redactSetCookieHeader redacts the cookie in the value of a Set-Cookie
+// header, and reports whether the header should be kept.
+func (r *Redactor) redactSetCookieHeader(value string) (string, bool) {
+	pair, attributes, hasAttributes := strings.Cut(value, ";")
+	name, cookieValue, _ := strings.Cut(strings.TrimSpace(pair), "=")
+
+	action, ok := r.match(r.cookies, name)
+	switch {
+	case !ok:
+		return value, true
+
+	case action == RedactRemove:
+		return "", false
+	}
+
+	value = name + "=" + r.redactString(cookieValue, action)
+	if hasAttributes {
+		value += ";" + attributes
+	}
+	return value, true
+}
+
+// redactURL redacts the query parameters of a URL.
+// URLs that cannot be parsed are left as they are.
+func (r *Redactor) redactURL(rawURL string) string {
+	if len(r.query) == 0 || !strings.Contains(rawURL, "?") {
+		return rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	u.RawQuery = r.redactURLEncoded(u.RawQuery, r.query)
+	return u.String()
+}
+
+// redactURLEncoded redacts the parameters of a URL encoded string,
+// keeping the order and encoding of the other parameters.
+func (r *Redactor) redactURLEncoded(encoded string, rules []nameRule) string {
+	if len(rules) == 0 || encoded == "" {
+		return encoded
+	}
+
+	var pairs []string
+	for _, pair := range strings.Split(encoded, "&") {
+		name, value, _ := strings.Cut(pair, "=")
+
+		if action, ok := r.match(rules, unescapeQueryComponent(name)); ok {
+			if action == RedactRemove {
+				continue
+			}
+			value = r.redactString(unescapeQueryComponent(value), action)
+			pair = name + "=" + url.QueryEscape(value)
+		}
+		pairs = append(pairs, pair)
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// redactJSON redacts the fields of a JSON document, and reports whether it
+// could be parsed. Empty documents and documents without redacted fields are
+// left untouched.
+func (r *Redactor) redactJSON(text string) (string, bool) {
+	if text == "" {
+		return text, true
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return "", false
+	}
+
+	// Anything after the document could hold fields as well.
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return "", false
+	}
+
+	value, changed := r.redactJSONValue(value, nil)
+	if !changed {
+		return text, true
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", false
+	}
+	return string(encoded), true
+}
+
+// redactJSONValue redacts the fields within a decoded JSON value,
+// which is found at the given path, and reports whether anything changed.
+func (r *Redactor) redactJSONValue(value any, path []string) (any, bool) {
+	changed := false
+
+	switch container := value.(type) {
+	case map[string]any:
+		for key, child := range container {
+			childPath := append(path, key)
+
+			if action, ok := r.matchField(childPath); ok {
+				if action == RedactRemove {
+					delete(container, key)
+				} else {
+					container[key] = r.redactJSONLeaf(child, action)
+				}
+				changed = true
+				continue
+			}
+
+			if redacted, ok := r.redactJSONValue(child, childPath); ok {
+				container[key] = redacted
+				changed = true
+			}
+		}
+
+	case []any:
+		redacted := make([]any, 0, len(container))
+		for i, child := range container {
+			childPath := append(path, strconv.Itoa(i))
+
+			if action, ok := r.matchField(childPath); ok {
+				changed = true
+				if action == RedactRemove {
+					continue
+				}
+				child = r.redactJSONLeaf(child, action)
+			} else if redactedChild, ok := r.redactJSONValue(child, childPath); ok {
+				child = redactedChild
+				changed = true
+			}
+
+			redacted = append(redacted, child)
+		}
+		value = redacted
+	}
+
+	return value, changed
+}
+
+// redactJSONLeaf redacts a JSON value as a whole.
+// Values other than strings are hashed in their JSON encoding.
+func (r *Redactor) redactJSONLeaf(value any, action RedactAction) any {
+	if s, ok := value.(string); ok {
+		return r.redactString(s, action)
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return RedactedValue
+	}
+	return r.redactString(string(encoded), action)
+}
+
+// redactString redacts a value that is kept.
+func (r *Redactor) redactString(value string, action RedactAction) string {
+	if action != RedactHash {
+		return RedactedValue
+	}
+
+	var h hash.Hash
+	if r.hashKey != nil {
+		h = hmac.New(sha256.New, r.hashKey)
+	} else {
+		h = sha256.New()
+	}
+	h.Write([]byte(value))
+
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// match returns the action of the last rule that matches the name, if any.
+func (r *Redactor) match(rules []nameRule, name string) (RedactAction, bool) {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].match(name) {
+			return rules[i].action, true
+		}
+	}
+	return 0, false
+}
+
+// matchField returns the action of the last field rule that matches the path,
+// if any.
+func (r *Redactor) matchField(path []string) (RedactAction, bool) {
+	for i := len(r.fields) - 1; i >= 0; i-- {
+		if r.fields[i].path.matches(path) {
+			return r.fields[i].action, true
+		}
+	}
+	return 0, false
+}
+
+// redactNamed returns a copy of items, with the values of the items whose
+// names match a rule redacted.
+func redactNamed[T any](
+	r *Redactor,
+	rules []nameRule,
+	items []T,
+	field func(*T) (name string, value *string),
+) []T {
+	if len(rules) == 0 || items == nil {
+		return items
+	}
+
+	redacted := make([]T, 0, len(items))
+	for _, item := range items {
+		name, value := field(&item)
+
+		if action, ok := r.match(rules, name); ok {
+			if action == RedactRemove {
+				continue
+			}
+			*value = r.redactString(*value, action)
+		}
+
+		redacted = append(redacted, item)
+	}
+	return redacted
+}
+
+func headerField(h *Header) (string, *string)           { return h.Name, &h.Value }
+func cookieField(c *Cookie) (string, *string)           { return c.Name, &c.Value }
+func queryStringField(q *QueryString) (string, *string) { return q.Name, &q.Value }
+func paramField(p *Param) (string, *string)             { return p.Name, &p.Value }
+
+// isJSONMimeType reports whether the MIME type describes a JSON document.
+func isJSONMimeType(mimeType string) bool {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" ||
+		mediaType == "text/json" ||
+		strings.HasSuffix(mediaType, "+json")
+}
+
+// fieldPath is a parsed path to fields within a JSON document.
+type fieldPath []fieldSegment
+
+type fieldSegment struct {
+	// key is the name of a field or the index of an element,
+	// or "*" for any field or element.
+	key string
+
+	// recursive is true if the segment can match at any depth.
+	recursive bool
+}
+
+func parseFieldPath(path string) (fieldPath, error) {
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return nil, fmt.Errorf("har: path %q must start with $", path)
+	}
+
+	var parsed fieldPath
+	for rest != "" {
+		var segment fieldSegment
+
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			segment.recursive = true
+			rest = rest[2:]
+
+		case rest[0] == '.':
+			rest = rest[1:]
+
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("har: path %q has an unterminated [", path)
+			}
+			segment.key = strings.Trim(rest[1:end], `'"`)
+			rest = rest[end+1:]
+
+			if segment.key == "" {
+				return nil, fmt.Errorf("har: path %q has an empty []", path)
+			}
+			parsed = append(parsed, segment)
+			continue
+
+		default:
+			return nil, fmt.Errorf("har: path %q has an unexpected %q", path, rest[0])
+		}
+
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		segment.key = rest[:end]
+		rest = rest[end:]
+
+		if segment.key == "" {
+			return nil, fmt.Errorf("har: path %q has an empty field name", path)
+		}
+		parsed = append(parsed, segment)
+	}
+
+	if len(parsed) == 0 {
+		return nil, errors.New("har: path must refer to a field, not the document")
+	}
+
+	return parsed, nil
+}
+
+// matches reports whether the path matches the path of a field,
+// given as the names and indices leading up to it.
+func (p fieldPath) matches(path []string) bool {
+	if len(p) == 0 {
+		return len(path) == 0
+	}
+
+	segment, rest := p[0], p[1:]
+
+	if segment.recursive {
+		for i := range path {
+			if segment.matchesKey(path[i]) && rest.matches(path[i+1:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return len(path) > 0 && segment.matchesKey(path[0]) && rest.matches(path[1:])
+}
+
+func (s fieldSegment) matchesKey(key string) bool {
+	return s.key == "*" || s.key == key
+}
@@ -0,0 +1,192 @@
+package har
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func newTestRedactor(t *testing.T, opts ...RedactOption) *Redactor {
+	t.Helper()
+
+	redactor, err := NewRedactor(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return redactor
+}
+
+func TestRedactRequestBody(t *testing.T) {
+	const secret = "hunter2"
+
+	tests := []struct {
+		name     string
+		mimeType string
+		body     string
+		limit    int
+
+		// text is the redacted text, after decoding it from base64,
+		// or empty if it is removed.
+		text string
+	}{
+		{
+			name:     "json",
+			mimeType: "application/json",
+			body:     `{"user":"alice","password":"hunter2"}`,
+			limit:    -1,
+			text:     `{"password":"REDACTED","user":"alice"}`,
+		},
+		{
+			name:     "truncated json",
+			mimeType: "application/json",
+			body:     `{"user":"alice","password":"hunter2"}`,
+			limit:    34,
+		},
+		{
+			name:     "json followed by more",
+			mimeType: "application/json",
+			body:     `{"user":"alice"} {"password":"hunter2"}`,
+			limit:    -1,
+		},
+		{
+			name:     "base64 json",
+			mimeType: "application/json",
+			body:     "{\"name\":\"\xff\",\"password\":\"hunter2\"}",
+			limit:    -1,
+			text:     `{"name":"` + "�" + `","password":"REDACTED"}`,
+		},
+		{
+			name:     "base64 form",
+			mimeType: "application/x-www-form-urlencoded",
+			body:     "name=%FF&password=hunter2&data=\xff",
+			limit:    -1,
+			text:     "name=%FF&password=REDACTED&data=\xff",
+		},
+		{
+			name:     "truncated form",
+			mimeType: "application/x-www-form-urlencoded",
+			body:     "user=alice&password=hunter2",
+			limit:    24,
+			text:     "user=alice&password=REDACTED",
+		},
+		{
+			name:     "truncated multipart",
+			mimeType: "multipart/form-data; boundary=b",
+			body:     "--b\r\nContent-Disposition: form-data; name=\"password\"\r\n\r\nhunter2\r\n--b--\r\n",
+			limit:    60,
+		},
+	}
+
+	redactor := newTestRedactor(t, RedactBodyFields(RedactMask, "$.password"))
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := Request{
+				Headers: []Header{{Name: "Content-Type", Value: test.mimeType}},
+			}
+			request.SetBody([]byte(test.body), len(test.body), WithMaxBodySize(test.limit))
+
+			redacted := redactor.Redact(Entry{Request: request})
+			postData := redacted.Request.PostData
+
+			text := postData.Text
+			if postData.Encoding == "base64" {
+				decoded, err := base64.StdEncoding.DecodeString(text)
+				if err != nil {
+					t.Fatal(err)
+				}
+				text = string(decoded)
+			}
+
+			if strings.Contains(text, secret) {
+				t.Errorf("Text = %q, which holds the secret", text)
+			}
+			for _, param := range postData.Params {
+				if strings.Contains(param.Value, secret) {
+					t.Errorf("Params = %+v, which hold the secret", postData.Params)
+				}
+			}
+
+			if text != test.text {
+				t.Errorf("Text = %q, want %q", text, test.text)
+			}
+			removed := strings.Contains(postData.Comment, unredactableComment)
+			if removed != (test.text == "") {
+				t.Errorf("Comment = %q, want it to say whether the text was removed", postData.Comment)
+			}
+
+			// The given entry is not modified.
+			if request.PostData.Text == "" {
+				t.Error("the posted data of the given entry was modified")
+			}
+		})
+	}
+}
+
+func TestRedactResponseContent(t *testing.T) {
+	body := `{"token":"secret","items":[1,2,3]}`
+
+	tests := []struct {
+		name  string
+		body  string
+		limit int
+		text  string
+	}{
+		{
+			name:  "json",
+			body:  body,
+			limit: -1,
+			text:  `{"items":[1,2,3],"token":"REDACTED"}`,
+		},
+		{
+			name:  "truncated json",
+			body:  body,
+			limit: 20,
+		},
+		{
+			name:  "base64 json",
+			body:  "{\"token\":\"secret\",\"data\":\"\xff\"}",
+			limit: -1,
+			text:  base64.StdEncoding.EncodeToString([]byte(`{"data":"` + "�" + `","token":"REDACTED"}`)),
+		},
+	}
+
+	redactor := newTestRedactor(t, RedactBodyFields(RedactMask, "$.token"))
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := Response{
+				Content: Content{MimeType: "application/json"},
+			}
+			response.SetBody([]byte(test.body), len(test.body), WithMaxBodySize(test.limit))
+
+			content := redactor.Redact(Entry{Response: response}).Response.Content
+			if content.Text != test.text {
+				t.Errorf("Text = %q, want %q", content.Text, test.text)
+			}
+
+			removed := strings.Contains(content.Comment, unredactableComment)
+			if removed != (test.text == "") {
+				t.Errorf("Comment = %q, want it to say whether the text was removed", content.Comment)
+			}
+			if test.limit >= 0 && !strings.Contains(content.Comment, "truncated") {
+				t.Errorf("Comment = %q, want it to keep the truncation", content.Comment)
+			}
+		})
+	}
+}
+
+func TestRedactWithoutFieldsKeepsBodies(t *testing.T) {
+	body := `{"token":"secret"`
+
+	response := Response{
+		Content: Content{MimeType: "application/json"},
+	}
+	response.SetBody([]byte(body), len(body))
+
+	redactor := newTestRedactor(t, RedactHeaders(RedactMask, "Authorization"))
+	content := redactor.Redact(Entry{Response: response}).Response.Content
+	if content.Text != body {
+		t.Errorf("Text = %q, want %q", content.Text, body)
+	}
+}
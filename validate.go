@@ -0,0 +1,220 @@
+package har
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+)
+
+// ValidationError describes a field that does not conform to the HAR 1.2
+// spec.
+type ValidationError struct {
+	// Field is the path to the field, e.g. "log.entries[0].request.url".
+	Field string
+
+	// Message describes what is wrong with the field.
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// validator collects validation errors.
+type validator struct {
+	errs []error
+}
+
+func (v *validator) fail(field string, format string, args ...any) {
+	v.errs = append(v.errs, &ValidationError{
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) require(ok bool, field string, format string, args ...any) {
+	if !ok {
+		v.fail(field, format, args...)
+	}
+}
+
+func (v *validator) err() error {
+	return errors.Join(v.errs...)
+}
+
+// Validate checks that the archive conforms to the HAR 1.2 spec.
+// All violations are reported, as *ValidationError values joined into a
+// single error.
+func (a *HttpArchive) Validate() error {
+	var v validator
+	v.archiveLog("log", &a.Log)
+	return v.err()
+}
+
+// Validate checks that the entry conforms to the HAR 1.2 spec.
+// All violations are reported, as *ValidationError values joined into a
+// single error.
+//
+// Since the entry is validated on its own, its page reference is not checked.
+func (e *Entry) Validate() error {
+	var v validator
+	v.entry("entry", e)
+	return v.err()
+}
+
+func (v *validator) archiveLog(field string, log *ArchiveLog) {
+	switch log.Version {
+	case "", "1.1", "1.2":
+	default:
+		v.fail(field+".version", "unsupported version %q", log.Version)
+	}
+
+	v.require(log.Creator.Name != "", field+".creator.name", "must not be empty")
+
+	if log.Browser != nil {
+		v.require(log.Browser.Name != "", field+".browser.name", "must not be empty")
+	}
+
+	pageIDs := make(map[string]bool, len(log.Pages))
+	for i := range log.Pages {
+		page := &log.Pages[i]
+		pageField := fmt.Sprintf("%s.pages[%d]", field, i)
+
+		v.page(pageField, page)
+
+		if pageIDs[page.ID] {
+			v.fail(pageField+".id", "duplicate page id %q", page.ID)
+		}
+		pageIDs[page.ID] = true
+	}
+
+	v.require(log.Entries != nil, field+".entries", "must be an array")
+	for i := range log.Entries {
+		entry := &log.Entries[i]
+		entryField := fmt.Sprintf("%s.entries[%d]", field, i)
+
+		v.entry(entryField, entry)
+
+		if entry.Pageref != "" && !pageIDs[entry.Pageref] {
+			v.fail(entryField+".pageref", "refers to unknown page %q", entry.Pageref)
+		}
+	}
+}
+
+func (v *validator) page(field string, page *Page) {
+	v.require(!page.StartedDateTime.IsZero(), field+".startedDateTime", "must be set")
+	v.require(page.ID != "", field+".id", "must not be empty")
+	v.optionalTiming(field+".pageTimings.onContentLoad", page.PageTimings.OnContentLoad)
+	v.optionalTiming(field+".pageTimings.onLoad", page.PageTimings.OnLoad)
+}
+
+func (v *validator) entry(field string, entry *Entry) {
+	v.require(!entry.StartedDateTime.IsZero(), field+".startedDateTime", "must be set")
+	v.request(field+".request", &entry.Request)
+	v.response(field+".response", &entry.Response)
+	v.cache(field+".cache", &entry.Cache)
+	v.timings(field+".timings", &entry.Timings)
+
+	v.require(entry.Time >= 0, field+".time", "must not be negative")
+
+	// Allow for rounding in applications that round each of the timings.
+	total := entry.Timings.Total()
+	v.require(
+		math.Abs(entry.Time-total) <= 1,
+		field+".time",
+		"must be the sum of the timings (%v), got %v",
+		total,
+		entry.Time,
+	)
+}
+
+func (v *validator) request(field string, request *Request) {
+	v.require(request.Method != "", field+".method", "must not be empty")
+
+	u, err := url.Parse(request.URL)
+	v.require(err == nil && u.IsAbs(), field+".url", "must be an absolute URL")
+
+	v.require(request.HTTPVersion != "", field+".httpVersion", "must not be empty")
+	v.cookies(field+".cookies", request.Cookies)
+	v.headers(field+".headers", request.Headers)
+
+	v.require(request.QueryString != nil, field+".queryString", "must be an array")
+	for i, query := range request.QueryString {
+		v.require(query.Name != "", fmt.Sprintf("%s.queryString[%d].name", field, i), "must not be empty")
+	}
+
+	if request.PostData != nil {
+		for i, param := range request.PostData.Params {
+			v.require(param.Name != "", fmt.Sprintf("%s.postData.params[%d].name", field, i), "must not be empty")
+		}
+	}
+
+	v.size(field+".headersSize", request.HeadersSize)
+	v.size(field+".bodySize", request.BodySize)
+}
+
+func (v *validator) response(field string, response *Response) {
+	// Failed requests are exported with status 0.
+	v.require(response.Status >= 0, field+".status", "must not be negative")
+	v.cookies(field+".cookies", response.Cookies)
+	v.headers(field+".headers", response.Headers)
+	v.size(field+".content.size", response.Content.Size)
+	v.require(response.Content.Compression >= 0, field+".content.compression", "must not be negative")
+	v.size(field+".headersSize", response.HeadersSize)
+	v.size(field+".bodySize", response.BodySize)
+}
+
+func (v *validator) cookies(field string, cookies []Cookie) {
+	v.require(cookies != nil, field, "must be an array")
+	for i, cookie := range cookies {
+		v.require(cookie.Name != "", fmt.Sprintf("%s[%d].name", field, i), "must not be empty")
+	}
+}
+
+func (v *validator) headers(field string, headers []Header) {
+	v.require(headers != nil, field, "must be an array")
+	for i, header := range headers {
+		v.require(header.Name != "", fmt.Sprintf("%s[%d].name", field, i), "must not be empty")
+	}
+}
+
+func (v *validator) cache(field string, cache *Cache) {
+	v.cacheEntryState(field+".beforeRequest", cache.BeforeRequest)
+	v.cacheEntryState(field+".afterRequest", cache.AfterRequest)
+}
+
+func (v *validator) cacheEntryState(field string, state *CacheEntryState) {
+	if state == nil {
+		return
+	}
+
+	v.require(state.LastAccess != "", field+".lastAccess", "must not be empty")
+	v.require(state.ETag != "", field+".eTag", "must not be empty")
+	v.require(state.HitCount >= 0, field+".hitCount", "must not be negative")
+}
+
+func (v *validator) timings(field string, timings *Timings) {
+	v.optionalTiming(field+".blocked", timings.Blocked)
+	v.optionalTiming(field+".dns", timings.DNS)
+	v.optionalTiming(field+".connect", timings.Connect)
+	v.optionalTiming(field+".ssl", timings.SSL)
+	v.require(timings.Send >= 0, field+".send", "must not be negative")
+	v.require(timings.Wait >= 0, field+".wait", "must not be negative")
+	v.require(timings.Receive >= 0, field+".receive", "must not be negative")
+
+	// The SSL time is included in the connect time.
+	if timings.SSL >= 0 {
+		v.require(timings.Connect >= timings.SSL, field+".connect", "must include the ssl time")
+	}
+}
+
+// optionalTiming checks a timing that is -1 if it does not apply.
+func (v *validator) optionalTiming(field string, timing float64) {
+	v.require(timing >= 0 || timing == -1, field, "must be -1 or not negative, got %v", timing)
+}
+
+// size checks a size that is -1 if it is not available.
+func (v *validator) size(field string, size int) {
+	v.require(size >= -1, field, "must be -1 or not negative, got %d", size)
+}
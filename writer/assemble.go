@@ -0,0 +1,253 @@
+package harwriter
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/oliverroer/go-har"
+)
+
+// DefaultCreator is the creator used by an Assembler without a creator.
+var DefaultCreator = har.Creator{
+	Name:    "github.com/oliverroer/go-har",
+	Version: "0.1.1",
+}
+
+// Assembler assembles HAR files from entry files,
+// adding the metadata of the log.
+type Assembler struct {
+	// Creator is the application that created the log,
+	// e.g. the name and build of the service that recorded the entries.
+	// DefaultCreator is used if the name is empty.
+	Creator har.Creator
+
+	// Browser is the browser used, if any.
+	Browser *har.Browser
+
+	// Pages are the pages that the entries can refer to.
+	Pages []har.Page
+
+	// Comment is a comment on the log.
+	Comment string
+}
+
+// EntriesToHar assembles a HAR file from the entries in the given entry files,
+// in the order they appear in, using DefaultCreator as the creator.
+//
+// See Assembler.EntriesToHar for details.
+func EntriesToHar(harFile string, entryFiles ...string) error {
+	var assembler Assembler
+	return assembler.EntriesToHar(harFile, entryFiles...)
+}
+
+// EntriesToHar assembles a HAR file from the entries in the given entry files,
+// in the order they appear in.
+//
+// Each entry is decoded before it is written to the HAR file, but it is not
+// validated, so entries are passed on as they were written.
+// Use har.EntryScanner and Entry.Validate to check the entries of a file.
+// Blank lines are skipped, as is an incomplete last line of an entry file,
+// which is what a process that crashed while writing leaves behind.
+// If an entry cannot be decoded, an *EntryFileError is returned,
+// and the HAR file is removed.
+func (a *Assembler) EntriesToHar(harFile string, entryFiles ...string) (err error) {
+	harFile = filepath.Clean(harFile)
+	file, err := os.Create(harFile)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = file.Close()
+			_ = os.Remove(harFile)
+		}
+	}()
+
+	writer := bufio.NewWriter(file)
+
+	err = a.Assemble(writer, entryFiles...)
+	if err != nil {
+		return err
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// Assemble writes a HAR document with the entries in the given entry files
+// to w. See EntriesToHar for details.
+func (a *Assembler) Assemble(w io.Writer, entryFiles ...string) error {
+	writer := &logWriter{writer: w}
+
+	creator := a.Creator
+	if creator.Name == "" {
+		creator = DefaultCreator
+	}
+
+	writer.begin()
+	writer.field("version", "1.2")
+	writer.field("creator", creator)
+	if a.Browser != nil {
+		writer.field("browser", a.Browser)
+	}
+
+	pages := slices.Clone(a.Pages)
+
+	writer.beginEntries()
+	for _, entryFile := range entryFiles {
+		filePages, err := readEntryFile(entryFile, func(entry har.Entry) error {
+			writer.entry(entry)
+			return writer.err
+		})
+		if err != nil {
+			return err
+		}
+		pages = append(pages, filePages...)
+	}
+	writer.endEntries()
+
+	// The pages written alongside the entries are only known once all the
+	// entries have been written, so they are placed after the entries.
+	if len(pages) > 0 {
+		writer.field("pages", pages)
+	}
+
+	if a.Comment != "" {
+		writer.field("comment", a.Comment)
+	}
+	writer.end()
+
+	return writer.err
+}
+
+// logWriter writes the log of a HAR document piece by piece,
+// so the entries do not have to be held in memory.
+// The first error encountered is kept, after which nothing more is written.
+type logWriter struct {
+	writer    io.Writer
+	err       error
+	separator string
+}
+
+const (
+	logIndent   = "    "
+	entryIndent = logIndent + "  "
+)
+
+func (w *logWriter) write(p []byte) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = w.writer.Write(p)
+}
+
+func (w *logWriter) writeString(s string) {
+	w.write([]byte(s))
+}
+
+func (w *logWriter) begin() {
+	w.writeString("{\n  \"log\": {")
+}
+
+func (w *logWriter) end() {
+	w.writeString("\n  }\n}\n")
+}
+
+func (w *logWriter) field(name string, value any) {
+	encoded, err := json.MarshalIndent(value, logIndent, "  ")
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+
+	w.writeString(w.separator + "\n" + logIndent + `"` + name + `": `)
+	w.write(encoded)
+	w.separator = ","
+}
+
+func (w *logWriter) beginEntries() {
+	w.writeString(w.separator + "\n" + logIndent + `"entries": [`)
+	w.separator = ""
+}
+
+func (w *logWriter) entry(entry har.Entry) {
+	encoded, err := json.Marshal(entry)
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+
+	w.writeString(w.separator + "\n" + entryIndent)
+	w.write(encoded)
+	w.separator = ","
+}
+
+func (w *logWriter) endEntries() {
+	w.writeString("\n" + logIndent + "]")
+	w.separator = ","
+}
+
+// EntryFileError describes an entry file that could not be read.
+type EntryFileError struct {
+	// File is the name of the entry file.
+	File string
+
+	// Line is the number of the line that could not be read,
+	// or 0 if the error is not related to a specific line.
+	Line int
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *EntryFileError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %v", e.File, e.Err)
+	}
+	return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+}
+
+func (e *EntryFileError) Unwrap() error {
+	return e.Err
+}
+
+// readEntryFile calls onEntry with each of the entries in the named entry
+// file, in the order they appear in, and returns the pages in the file.
+//
+// The file is read with a har.EntryScanner, so lines can be of any length,
+// blank lines are skipped, as is an incomplete last line, and a compressed
+// stream that ends prematurely is treated like the end of the file.
+func readEntryFile(name string, onEntry func(har.Entry) error) ([]har.Page, error) {
+	file, err := OpenEntryFile(name)
+	if err != nil {
+		return nil, &EntryFileError{File: name, Err: err}
+	}
+	defer file.Close()
+
+	scanner := har.NewEntryScanner(file)
+	for scanner.Scan() {
+		if err := onEntry(scanner.Entry()); err != nil {
+			return nil, err
+		}
+	}
+
+	err = scanner.Err()
+	var lineErr *har.LineError
+	if errors.As(err, &lineErr) {
+		return nil, &EntryFileError{File: name, Line: lineErr.Line, Err: lineErr.Err}
+	}
+	if err != nil {
+		return nil, &EntryFileError{File: name, Err: err}
+	}
+
+	return scanner.Pages(), nil
+}
@@ -0,0 +1,138 @@
+package harwriter
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oliverroer/go-har"
+)
+
+// TestEntriesToHarValid records real round trips, both through the
+// RoundTripper and the Handler, and checks that the assembled HAR file
+// conforms to the spec.
+func TestEntriesToHarValid(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, "hello")
+	})
+	mux.HandleFunc("/gzip", func(w http.ResponseWriter, r *http.Request) {
+		body := strings.Repeat(`{"a":1}`, 100)
+		if r.URL.Query().Has("small") {
+			body = `{"a":1}`
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		_, _ = io.WriteString(gz, body)
+		_ = gz.Close()
+	})
+	mux.HandleFunc("/post", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write(body)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	closed := httptest.NewServer(mux)
+	closed.Close()
+
+	dir := t.TempDir()
+	entryFile := filepath.Join(dir, "entries.jsonl")
+	writer, err := Open(entryFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handled := httptest.NewServer(writer.Handler(mux))
+	defer handled.Close()
+
+	// Compressed bodies are recorded as they were received.
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.DisableCompression = true
+	defer base.CloseIdleConnections()
+
+	clients := []*http.Client{
+		{Transport: writer.RoundTripper(base)},
+		{Transport: writer.RoundTripper(base, WithStreaming())},
+		{Transport: writer.RoundTripper(base, WithMaxRequestBodySize(4), WithMaxResponseBodySize(4))},
+	}
+
+	requests := []struct {
+		method string
+		url    string
+		body   string
+	}{
+		{method: http.MethodGet, url: server.URL + "/plain"},
+		{method: http.MethodGet, url: server.URL + "/gzip"},
+		{method: http.MethodGet, url: server.URL + "/gzip?small"},
+		{method: http.MethodPost, url: server.URL + "/post", body: "a=1&b=2"},
+		{method: http.MethodGet, url: closed.URL + "/plain"},
+	}
+
+	send := func(client *http.Client, method, url, body string) {
+		req, err := http.NewRequest(method, url, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body != "" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+
+		res, err := client.Do(req)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, res.Body)
+		_ = res.Body.Close()
+	}
+
+	for _, client := range clients {
+		for _, request := range requests {
+			send(client, request.method, request.url, request.body)
+		}
+	}
+
+	for _, request := range requests[:4] {
+		url := handled.URL + strings.TrimPrefix(request.url, server.URL)
+		send(http.DefaultClient, request.method, url, request.body)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	harFile := filepath.Join(dir, "out.har")
+	if err := EntriesToHar(harFile, entryFile); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(harFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	archive, err := har.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := len(clients)*len(requests) + 4
+	if len(archive.Log.Entries) != want {
+		t.Errorf("len(Entries) = %d, want %d", len(archive.Log.Entries), want)
+	}
+
+	if err := archive.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package harwriter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrClosed is returned when writing to an EntryWriter or a ChanSink that has
+// been closed.
+var ErrClosed = errors.New("harwriter: writer is closed")
+
+// QueuePolicy decides what happens when an entry is written asynchronously
+// while the queue of pending entries is full.
+type QueuePolicy int
+
+const (
+	// QueueBlock blocks the write until there is room in the queue.
+	QueueBlock QueuePolicy = iota
+
+	// QueueDropNewest discards the entry being written.
+	QueueDropNewest
+
+	// QueueDropOldest discards the oldest pending entry to make room for the
+	// entry being written.
+	QueueDropOldest
+)
+
+// asyncQueue holds the records that are waiting to be written by the
+// background goroutine of an asynchronous EntryWriter.
+type asyncQueue struct {
+	records chan record
+	policy  QueuePolicy
+	dropped atomic.Uint64
+
+	// closeMu is held for reading while entries are enqueued,
+	// and for writing while the queue is closed,
+	// so we never send on a closed channel.
+	closeMu sync.RWMutex
+	closed  bool
+
+	// mu guards the counters used to wait for pending entries.
+	mu       sync.Mutex
+	enqueued uint64
+	done     uint64
+	progress chan struct{}
+	err      error
+
+	stopped chan struct{}
+}
+
+func newAsyncQueue(size int, policy QueuePolicy) *asyncQueue {
+	return &asyncQueue{
+		records:  make(chan record, size),
+		policy:   policy,
+		progress: make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// run writes the queued records until the queue is closed,
+// flushing whenever the queue runs empty.
+func (q *asyncQueue) run(write func(record) error, flush func() error) {
+	defer close(q.stopped)
+
+	for rec := range q.records {
+		err := write(rec)
+		if len(q.records) == 0 && err == nil {
+			err = flush()
+		}
+		q.advance(err)
+	}
+}
+
+func (q *asyncQueue) enqueue(rec record) error {
+	q.closeMu.RLock()
+	defer q.closeMu.RUnlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+
+	q.mu.Lock()
+	q.enqueued++
+	q.mu.Unlock()
+
+	switch q.policy {
+	case QueueDropNewest:
+		select {
+		case q.records <- rec:
+		default:
+			q.drop()
+		}
+
+	case QueueDropOldest:
+		for {
+			select {
+			case q.records <- rec:
+				return nil
+			default:
+			}
+
+			select {
+			case <-q.records:
+				q.drop()
+			default:
+			}
+		}
+
+	default:
+		q.records <- rec
+	}
+
+	return nil
+}
+
+func (q *asyncQueue) drop() {
+	q.dropped.Add(1)
+	q.advance(nil)
+}
+
+// advance marks a pending entry as done, either because it was written or
+// because it was dropped, and wakes up anyone waiting for pending entries.
+func (q *asyncQueue) advance(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.done++
+	if err != nil && q.err == nil {
+		q.err = err
+	}
+
+	close(q.progress)
+	q.progress = make(chan struct{})
+}
+
+// wait waits until all entries enqueued before the call are done,
+// and returns the first error encountered while writing, if any.
+func (q *asyncQueue) wait(ctx context.Context) error {
+	q.mu.Lock()
+	target := q.enqueued
+	q.mu.Unlock()
+
+	for {
+		q.mu.Lock()
+		done := q.done
+		progress := q.progress
+		err := q.err
+		q.err = nil
+		q.mu.Unlock()
+
+		if err != nil {
+			return err
+		}
+
+		if done >= target {
+			return nil
+		}
+
+		select {
+		case <-progress:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// close stops accepting entries and waits for the pending ones to be written.
+func (q *asyncQueue) close() error {
+	q.closeMu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.records)
+	}
+	q.closeMu.Unlock()
+
+	<-q.stopped
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	err := q.err
+	q.err = nil
+	return err
+}
@@ -0,0 +1,109 @@
+package harwriter
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/oliverroer/go-har"
+)
+
+// blockingSink is a MemorySink whose writes block until it is released.
+type blockingSink struct {
+	*MemorySink
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingSink() *blockingSink {
+	return &blockingSink{
+		MemorySink: NewMemorySink(),
+		started:    make(chan struct{}, 16),
+		release:    make(chan struct{}),
+	}
+}
+
+func (s *blockingSink) WriteEntry(entry har.Entry) error {
+	s.started <- struct{}{}
+	<-s.release
+	return s.MemorySink.WriteEntry(entry)
+}
+
+func TestEntryWriterAsyncDrop(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy QueuePolicy
+		want   []string
+	}{
+		{name: "newest", policy: QueueDropNewest, want: []string{"0", "1", "2"}},
+		{name: "oldest", policy: QueueDropOldest, want: []string{"0", "3", "4"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sink := newBlockingSink()
+			writer := NewEntryWriter(sink, WithAsync(2, test.policy))
+
+			// The first entry is taken from the queue and blocks in the sink,
+			// so the queue is full after the next two.
+			if err := writer.Write(har.Entry{Comment: "0"}); err != nil {
+				t.Fatalf("Write() = %v", err)
+			}
+			<-sink.started
+
+			for i := 1; i < 5; i++ {
+				if err := writer.Write(har.Entry{Comment: strconv.Itoa(i)}); err != nil {
+					t.Fatalf("Write() = %v", err)
+				}
+			}
+
+			if got := writer.Dropped(); got != 2 {
+				t.Errorf("Dropped() = %d, want 2", got)
+			}
+
+			close(sink.release)
+			if err := writer.Flush(context.Background()); err != nil {
+				t.Fatalf("Flush() = %v", err)
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatalf("Close() = %v", err)
+			}
+
+			var got []string
+			for _, entry := range sink.Entries() {
+				got = append(got, entry.Comment)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("entries = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEntryWriterAsyncFlushExpired(t *testing.T) {
+	sink := newBlockingSink()
+	writer := NewEntryWriter(sink, WithAsync(2, QueueBlock))
+
+	if err := writer.Write(har.Entry{}); err != nil {
+		t.Fatalf("Write() = %v", err)
+	}
+	<-sink.started
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+
+	if err := writer.Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Flush() = %v, want context.DeadlineExceeded", err)
+	}
+
+	close(sink.release)
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if got := len(sink.Entries()); got != 1 {
+		t.Errorf("len(Entries()) = %d, want 1", got)
+	}
+}
@@ -0,0 +1,136 @@
+package harwriter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// recordingBody records the bytes of a body as they are read by the caller,
+// without delaying or buffering the reads themselves.
+//
+// At most limit bytes are recorded, unless limit is negative, but all bytes
+// that are read are counted.
+// Once the body has been read in its entirety or has been closed,
+// whichever comes first, done is called with the recorded bytes, the
+// number of bytes read, and whether the body was read in its entirety.
+type recordingBody struct {
+	body  io.ReadCloser
+	limit int
+	done  func(recorded []byte, size int, complete bool)
+
+	mu       sync.Mutex
+	buffer   bytes.Buffer
+	size     int
+	finished bool
+	complete bool
+}
+
+// newRecordingBody wraps body for recording.
+// If there is no body to record, done is called immediately.
+func newRecordingBody(
+	body io.ReadCloser,
+	limit int,
+	done func(recorded []byte, size int, complete bool),
+) io.ReadCloser {
+	if body == nil || body == http.NoBody {
+		done(nil, 0, true)
+		return body
+	}
+
+	return &recordingBody{
+		body:  body,
+		limit: limit,
+		done:  done,
+	}
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+
+	b.mu.Lock()
+	if !b.finished {
+		b.record(p[:n])
+	}
+	b.mu.Unlock()
+
+	if errors.Is(err, io.EOF) {
+		b.finish(true)
+	}
+
+	return n, err
+}
+
+func (b *recordingBody) record(p []byte) {
+	b.size += len(p)
+
+	if b.limit >= 0 {
+		remaining := b.limit - b.buffer.Len()
+		if remaining < len(p) {
+			p = p[:remaining]
+		}
+	}
+
+	b.buffer.Write(p)
+}
+
+func (b *recordingBody) Close() error {
+	err := b.body.Close()
+	b.finish(false)
+	return err
+}
+
+// finish ends the recording, if it has not ended yet.
+// complete reports whether the body was read in its entirety.
+func (b *recordingBody) finish(complete bool) {
+	b.mu.Lock()
+	if b.finished {
+		b.mu.Unlock()
+		return
+	}
+	b.finished = true
+	b.complete = complete
+
+	recorded := b.buffer.Bytes()
+	if recorded == nil {
+		recorded = []byte{}
+	}
+	size := b.size
+	b.mu.Unlock()
+
+	b.done(recorded, size, complete)
+}
+
+// recorded returns a copy of what has been recorded so far, the number of
+// bytes read, and whether the body has been read in its entirety.
+func (b *recordingBody) recorded() ([]byte, int, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return bytes.Clone(b.buffer.Bytes()), b.size, b.complete
+}
+
+// unreadSize returns the size of a body of which size bytes were read before
+// it was closed, given its Content-Length, which is -1 if it is unknown.
+// It reports false if the size is unknown, since the body was not read in its
+// entirety.
+func unreadSize(size int, contentLength int64) (int, bool) {
+	if contentLength < 0 {
+		return size, false
+	}
+	return max(size, int(contentLength)), true
+}
+
+// unreadComment is the comment on bodies of unknown size that were closed
+// before they were read in their entirety.
+const unreadComment = "the body was closed before it was read to the end, so the rest of it may be missing"
+
+// appendComment adds a comment to an existing one.
+func appendComment(comment string, addition string) string {
+	if comment == "" {
+		return addition
+	}
+	return comment + "; " + addition
+}
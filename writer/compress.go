@@ -0,0 +1,139 @@
+package harwriter
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Compressor returns a writer that compresses the data written to it and
+// writes it to w.
+// If the returned writer has a Flush method, it is called whenever the sink
+// it belongs to is flushed, so that the data written so far can be recovered
+// even if the file is never closed.
+type Compressor func(w io.Writer) (io.WriteCloser, error)
+
+// Decompressor returns a reader that decompresses the data read from r.
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+type compression struct {
+	compressor   Compressor
+	decompressor Decompressor
+}
+
+var (
+	compressionsMu sync.RWMutex
+	compressions   = map[string]compression{
+		".gz": {
+			compressor: func(w io.Writer) (io.WriteCloser, error) {
+				return gzip.NewWriter(w), nil
+			},
+			decompressor: func(r io.Reader) (io.ReadCloser, error) {
+				return gzip.NewReader(r)
+			},
+		},
+	}
+)
+
+// RegisterCompression registers the compression used for files with the
+// given extension, replacing any compression already registered for it.
+//
+// Compression with gzip is registered for ".gz" by default.
+// Other formats, such as zstd, can be supported by registering an adapter for
+// a third party package, e.g.:
+//
+//	harwriter.RegisterCompression(
+//		".zst",
+//		func(w io.Writer) (io.WriteCloser, error) {
+//			return zstd.NewWriter(w)
+//		},
+//		func(r io.Reader) (io.ReadCloser, error) {
+//			decoder, err := zstd.NewReader(r)
+//			if err != nil {
+//				return nil, err
+//			}
+//			return decoder.IOReadCloser(), nil
+//		},
+//	)
+func RegisterCompression(
+	extension string,
+	compressor Compressor,
+	decompressor Decompressor,
+) {
+	compressionsMu.Lock()
+	defer compressionsMu.Unlock()
+
+	compressions[strings.ToLower(extension)] = compression{
+		compressor:   compressor,
+		decompressor: decompressor,
+	}
+}
+
+func lookupCompression(extension string) (compression, bool) {
+	compressionsMu.RLock()
+	defer compressionsMu.RUnlock()
+
+	c, ok := compressions[strings.ToLower(extension)]
+	return c, ok
+}
+
+// compressionForName returns the compression registered for the extension of
+// the named file, if any.
+func compressionForName(name string) (compression, bool) {
+	return lookupCompression(filepath.Ext(name))
+}
+
+// OpenEntryFile opens a file of entries for reading,
+// transparently decompressing it if its extension has a registered
+// compression, e.g. ".jsonl.gz".
+//
+// Files that were not closed properly, e.g. because the writing process
+// crashed, may end with a truncated compressed stream,
+// in which case reading returns io.ErrUnexpectedEOF after the recoverable
+// data. Files that end before the start of the compressed stream, e.g. empty
+// files, are read as empty.
+func OpenEntryFile(name string) (io.ReadCloser, error) {
+	file, err := os.Open(filepath.Clean(name))
+	if err != nil {
+		return nil, err
+	}
+
+	c, ok := compressionForName(name)
+	if !ok {
+		return file, nil
+	}
+
+	reader, err := c.decompressor(file)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		// The writing process stopped before the start of the compressed
+		// stream was written, so there are no entries.
+		reader, err = io.NopCloser(strings.NewReader("")), nil
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return &decompressedFile{
+		ReadCloser: reader,
+		file:       file,
+	}, nil
+}
+
+// decompressedFile closes both the decompressor and the file it reads from.
+type decompressedFile struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (f *decompressedFile) Close() error {
+	err := f.ReadCloser.Close()
+	if fileErr := f.file.Close(); err == nil {
+		err = fileErr
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+package harwriter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oliverroer/go-har"
+)
+
+func TestEntriesToHarEmptyCompressedFile(t *testing.T) {
+	dir := t.TempDir()
+
+	written := filepath.Join(dir, "written.jsonl.gz")
+	writer, err := Open(written)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Write(har.Entry{Request: har.Request{URL: "https://example.com/"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := os.ReadFile(written)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A process that crashes before its first flush leaves an empty file,
+	// or one that ends within the gzip header.
+	empty := filepath.Join(dir, "empty.jsonl.gz")
+	header := filepath.Join(dir, "header.jsonl.gz")
+	if err := os.WriteFile(empty, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(header, contents[:4], 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	harFile := filepath.Join(dir, "out.har")
+	if err := EntriesToHar(harFile, empty, written, header); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(harFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	archive, err := har.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.Log.Entries) != 1 {
+		t.Errorf("len(Entries) = %d, want 1", len(archive.Log.Entries))
+	}
+}
@@ -0,0 +1,295 @@
+package harwriter
+
+import (
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Filter decides whether a round trip is recorded.
+//
+// Filters that only look at the request, such as MatchHost, MatchPath and
+// MatchMethod, are applied before the round trip, so that nothing is captured
+// for the round trips they exclude, not even the request body.
+// Other filters are applied once the response headers have been received,
+// before the response body is read. The response is nil if the round trip
+// failed, in which case filters that look at the response do not match.
+//
+// The zero Filter matches every round trip.
+type Filter struct {
+	// match reports whether the filter matches the round trip.
+	match func(req *http.Request, res *http.Response) bool
+
+	// matchRequest reports whether the filter matches the round trip, and
+	// whether that is decided by the request alone, before the round trip.
+	// It is nil for filters that need the response.
+	matchRequest func(req *http.Request) (matched bool, decided bool)
+}
+
+// NewFilter returns a filter that matches the round trips that match reports,
+// which is called once the response headers have been received.
+// match must be safe for concurrent use.
+func NewFilter(match func(req *http.Request, res *http.Response) bool) Filter {
+	return Filter{match: match}
+}
+
+// NewRequestFilter returns a filter that matches the round trips whose
+// request match reports, which is called before the round trip,
+// and possibly again after it.
+// match must be safe for concurrent use, and must not have side effects.
+func NewRequestFilter(match func(req *http.Request) bool) Filter {
+	return Filter{
+		match: func(req *http.Request, _ *http.Response) bool {
+			return match(req)
+		},
+		matchRequest: func(req *http.Request) (bool, bool) {
+			return match(req), true
+		},
+	}
+}
+
+// Match reports whether the filter matches the round trip,
+// whose response is nil if it failed.
+func (f Filter) Match(req *http.Request, res *http.Response) bool {
+	if f.match == nil {
+		return true
+	}
+	return f.match(req, res)
+}
+
+// matchesRequest reports whether the filter matches the round trip, and
+// whether that is decided by the request alone.
+func (f Filter) matchesRequest(req *http.Request) (matched bool, decided bool) {
+	switch {
+	case f.matchRequest != nil:
+		return f.matchRequest(req)
+
+	case f.match == nil:
+		return true, true
+
+	default:
+		return false, false
+	}
+}
+
+// WithInclude only records the round trips that the filter matches.
+// If given more than once, all filters have to match.
+func WithInclude(filter Filter) TransportOption {
+	return func(t *harRoundTripper) {
+		t.filters = append(t.filters, filter)
+	}
+}
+
+// WithExclude does not record the round trips that the filter matches.
+// If given more than once, none of the filters may match.
+func WithExclude(filter Filter) TransportOption {
+	return WithInclude(Not(filter))
+}
+
+// MatchHost matches requests to hosts that match any of the patterns,
+// e.g. "api.example.com" or "*.example.com". The port is not part of the host.
+// Patterns use the syntax of path.Match, and are matched case-insensitively.
+func MatchHost(patterns ...string) Filter {
+	return NewRequestFilter(func(req *http.Request) bool {
+		return matchAny(patterns, strings.ToLower(req.URL.Hostname()), strings.ToLower)
+	})
+}
+
+// MatchPath matches requests whose URL path matches any of the patterns,
+// e.g. "/healthz" or "/api/*/status".
+// Patterns use the syntax of path.Match, so "*" does not match "/".
+func MatchPath(patterns ...string) Filter {
+	return NewRequestFilter(func(req *http.Request) bool {
+		return matchAny(patterns, req.URL.Path, nil)
+	})
+}
+
+// MatchMethod matches requests with any of the methods,
+// which are matched case-insensitively.
+func MatchMethod(methods ...string) Filter {
+	return NewRequestFilter(func(req *http.Request) bool {
+		for _, method := range methods {
+			if strings.EqualFold(method, req.Method) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// MatchStatus matches responses with a status code between low and high,
+// inclusive, e.g. MatchStatus(500, 599) for server errors.
+func MatchStatus(low, high int) Filter {
+	return NewFilter(func(_ *http.Request, res *http.Response) bool {
+		return res != nil && res.StatusCode >= low && res.StatusCode <= high
+	})
+}
+
+// MatchContentType matches responses whose media type matches any of the
+// patterns, e.g. "application/json" or "image/*".
+// Parameters such as the charset are not part of the media type.
+// Patterns use the syntax of path.Match, and are matched case-insensitively.
+func MatchContentType(patterns ...string) Filter {
+	return NewFilter(func(_ *http.Request, res *http.Response) bool {
+		if res == nil {
+			return false
+		}
+
+		mediaType, _, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+		if err != nil {
+			return false
+		}
+
+		return matchAny(patterns, mediaType, strings.ToLower)
+	})
+}
+
+// And matches round trips that all of the filters match.
+// Filters are called in order, until one of them does not match.
+//
+// Before the round trip, And does not match if any of the filters that only
+// look at the request does not match.
+func And(filters ...Filter) Filter {
+	return Filter{
+		match: func(req *http.Request, res *http.Response) bool {
+			for _, filter := range filters {
+				if !filter.Match(req, res) {
+					return false
+				}
+			}
+			return true
+		},
+		matchRequest: func(req *http.Request) (bool, bool) {
+			decided := true
+			for _, filter := range filters {
+				matched, filterDecided := filter.matchesRequest(req)
+				if filterDecided && !matched {
+					return false, true
+				}
+				decided = decided && filterDecided
+			}
+			return decided, decided
+		},
+	}
+}
+
+// Or matches round trips that any of the filters match.
+// Filters are called in order, until one of them matches.
+//
+// Before the round trip, Or matches if any of the filters that only look at
+// the request matches.
+func Or(filters ...Filter) Filter {
+	return Filter{
+		match: func(req *http.Request, res *http.Response) bool {
+			for _, filter := range filters {
+				if filter.Match(req, res) {
+					return true
+				}
+			}
+			return false
+		},
+		matchRequest: func(req *http.Request) (bool, bool) {
+			decided := true
+			for _, filter := range filters {
+				matched, filterDecided := filter.matchesRequest(req)
+				if filterDecided && matched {
+					return true, true
+				}
+				decided = decided && filterDecided
+			}
+			return false, decided
+		},
+	}
+}
+
+// Not matches round trips that the filter does not match.
+func Not(filter Filter) Filter {
+	return Filter{
+		match: func(req *http.Request, res *http.Response) bool {
+			return !filter.Match(req, res)
+		},
+		matchRequest: func(req *http.Request) (bool, bool) {
+			matched, decided := filter.matchesRequest(req)
+			return !matched, decided
+		},
+	}
+}
+
+// Sample matches a random fraction of round trips, given by rate,
+// which ranges from 0 (none) to 1 (all).
+//
+// Combine it with other filters to sample some round trips and not others,
+// e.g. to record all server errors, but only 1% of successful responses:
+//
+//	Or(MatchStatus(500, 599), And(MatchStatus(200, 299), Sample(0.01)))
+//
+// Like filters that look at the response, Sample is only applied after the
+// round trip, so that each round trip is sampled once.
+func Sample(rate float64) Filter {
+	return NewFilter(func(*http.Request, *http.Response) bool {
+		return rand.Float64() < rate
+	})
+}
+
+// RateLimit matches at most n round trips per interval.
+// Unused capacity does not carry over from one interval to the next.
+//
+// Since the limit is consumed whenever the filter is called, it should
+// come last in an And, so that it only counts round trips that would
+// otherwise be recorded. Like filters that look at the response, RateLimit is
+// only applied after the round trip.
+func RateLimit(n int, interval time.Duration) Filter {
+	limiter := &rateLimiter{
+		limit:    n,
+		interval: interval,
+	}
+	return NewFilter(func(*http.Request, *http.Response) bool {
+		return limiter.allow(time.Now())
+	})
+}
+
+// rateLimiter counts the round trips in fixed windows of time.
+type rateLimiter struct {
+	mu       sync.Mutex
+	limit    int
+	interval time.Duration
+	start    time.Time
+	count    int
+}
+
+func (l *rateLimiter) allow(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.start) >= l.interval {
+		l.start = now
+		l.count = 0
+	}
+
+	if l.count >= l.limit {
+		return false
+	}
+
+	l.count++
+	return true
+}
+
+// matchAny reports whether the name matches any of the patterns,
+// which are normalized before matching, if normalize is not nil.
+// Malformed patterns do not match.
+func matchAny(patterns []string, name string, normalize func(string) string) bool {
+	for _, pattern := range patterns {
+		if normalize != nil {
+			pattern = normalize(pattern)
+		}
+
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
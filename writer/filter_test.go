@@ -0,0 +1,97 @@
+package harwriter
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"testing"
+)
+
+func TestFilterMatchesRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  Filter
+		matched bool
+		decided bool
+	}{
+		{"zero", Filter{}, true, true},
+		{"host", MatchHost("*.example.com"), true, true},
+		{"other host", MatchHost("example.org"), false, true},
+		{"status", MatchStatus(200, 299), false, false},
+		{"sample", Sample(1), false, false},
+		{"and excluded", And(MatchStatus(200, 299), MatchPath("/other")), false, true},
+		{"and undecided", And(MatchPath("/api/*"), MatchStatus(200, 299)), false, false},
+		{"and matched", And(MatchPath("/api/*"), MatchMethod("post")), true, true},
+		{"or matched", Or(MatchStatus(500, 599), MatchMethod("POST")), true, true},
+		{"or undecided", Or(MatchStatus(500, 599), MatchMethod("GET")), false, false},
+		{"or excluded", Or(MatchHost("example.org"), MatchMethod("GET")), false, true},
+		{"not", Not(MatchPath("/api/*")), false, true},
+		{"not undecided", Not(MatchContentType("image/*")), true, false},
+		{"custom", NewFilter(func(*http.Request, *http.Response) bool { return true }), false, false},
+		{"custom request", NewRequestFilter(func(*http.Request) bool { return false }), false, true},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "https://api.example.com/api/items", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matched, decided := test.filter.matchesRequest(req)
+			if decided != test.decided || (decided && matched != test.matched) {
+				t.Errorf(
+					"matchesRequest() = %v, %v, want %v, %v",
+					matched, decided, test.matched, test.decided,
+				)
+			}
+		})
+	}
+}
+
+// roundTripFunc is an http.RoundTripper that calls itself.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTransportExcludedByRequest(t *testing.T) {
+	body := io.NopCloser(strings.NewReader("payload"))
+
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Body != body {
+			t.Error("the request body of an excluded round trip was captured")
+		}
+		if httptrace.ContextClientTrace(req.Context()) != nil {
+			t.Error("an excluded round trip was traced")
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	})
+
+	sink := NewMemorySink()
+	writer := NewEntryWriter(sink)
+	transport := writer.RoundTripper(base, WithExclude(MatchPath("/healthz")))
+
+	req, err := http.NewRequest(http.MethodPost, "http://example.com/healthz", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+
+	if got := len(sink.Entries()); got != 0 {
+		t.Errorf("len(Entries()) = %d, want 0", got)
+	}
+}
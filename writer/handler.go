@@ -0,0 +1,319 @@
+package harwriter
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/oliverroer/go-har"
+)
+
+var _ http.Handler = (*harHandler)(nil)
+
+type harHandler struct {
+	next                http.Handler
+	writer              *EntryWriter
+	maxRequestBodySize  int
+	maxResponseBodySize int
+}
+
+// HandlerOption configures the http.Handler returned by EntryWriter.Handler.
+type HandlerOption func(*harHandler)
+
+// WithHandlerMaxRequestBodySize limits the number of request body bytes that
+// are recorded to n. See WithMaxRequestBodySize.
+func WithHandlerMaxRequestBodySize(n int) HandlerOption {
+	return func(h *harHandler) {
+		h.maxRequestBodySize = n
+	}
+}
+
+// WithHandlerMaxResponseBodySize limits the number of response body bytes
+// that are recorded to n, which is also the most that is ever held in memory
+// for recording. See WithMaxResponseBodySize.
+func WithHandlerMaxResponseBodySize(n int) HandlerOption {
+	return func(h *harHandler) {
+		h.maxResponseBodySize = n
+	}
+}
+
+// Handler returns an http.Handler that records the requests served by next,
+// and the responses it writes, as entries written to w.
+//
+// Bodies are recorded as they are read and written by next, without being
+// buffered, so streaming handlers keep working. Only the part of the request
+// body that next reads is recorded, and the bytes it does not read are
+// missing. Such request bodies are marked as truncated if their
+// Content-Length is known, and have a comment saying so otherwise.
+// The entry is written once next returns, or panics.
+//
+// The http.ResponseWriter passed to next supports http.Flusher,
+// http.Hijacker and http.ResponseController, as long as the underlying
+// http.ResponseWriter does. Nothing written to a hijacked connection is
+// recorded.
+func (w *EntryWriter) Handler(next http.Handler, opts ...HandlerOption) http.Handler {
+	handler := &harHandler{
+		next:                next,
+		writer:              w,
+		maxRequestBodySize:  -1,
+		maxResponseBodySize: -1,
+	}
+
+	for _, opt := range opts {
+		opt(handler)
+	}
+
+	return handler
+}
+
+func (h *harHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	start := time.Now()
+
+	var (
+		requestBody     []byte
+		requestBodySize int
+		requestBodyRead = true
+	)
+	body := newRecordingBody(req.Body, h.maxRequestBodySize, func(recorded []byte, size int, complete bool) {
+		requestBody, requestBodySize = recorded, size
+
+		// The part of the body that next did not read is missing,
+		// so the recorded body is truncated.
+		if !complete {
+			requestBodySize, requestBodyRead = unreadSize(size, req.ContentLength)
+		}
+	})
+
+	recorder := &recordingResponseWriter{
+		ResponseWriter: rw,
+		limit:          h.maxResponseBodySize,
+	}
+
+	// Deferred, so that what was sent is also recorded if next panics,
+	// e.g. with http.ErrAbortHandler.
+	defer func() {
+		// The server closes the request body once we return,
+		// so whatever has not been read by now never will be.
+		if body, ok := body.(*recordingBody); ok {
+			body.finish(false)
+		}
+
+		harRequest := har.RequestFromHttpRequest(serverRequest(req), har.WithoutBody())
+		if requestBody != nil {
+			harRequest.SetBody(
+				requestBody,
+				requestBodySize,
+				har.WithMaxBodySize(h.maxRequestBodySize),
+			)
+			if !requestBodyRead {
+				harRequest.PostData.Comment = appendComment(harRequest.PostData.Comment, unreadComment)
+			}
+		}
+
+		h.writeEntry(req, start, recorder, harRequest)
+	}()
+
+	served := req.WithContext(req.Context())
+	served.Body = body
+	h.next.ServeHTTP(recorder, served)
+}
+
+func (h *harHandler) writeEntry(
+	req *http.Request,
+	start time.Time,
+	recorder *recordingResponseWriter,
+	harRequest har.Request,
+) {
+	end := time.Now()
+	harResponse := recorder.response(req)
+
+	// We only know when the response was started and when the handler
+	// returned, so everything before the response is spent waiting,
+	// and everything after it is spent sending the response.
+	firstByte := recorder.firstByte
+	if firstByte.IsZero() {
+		firstByte = end
+	}
+
+	timings := har.Timings{
+		Blocked: -1,
+		DNS:     -1,
+		Connect: -1,
+		SSL:     -1,
+		Wait:    millis(start, firstByte),
+		Receive: millis(firstByte, end),
+	}
+
+	entry := har.Entry{
+		Pageref:         PageRefFromContext(req.Context()),
+		StartedDateTime: start,
+		Time:            timings.Total(),
+		Request:         harRequest,
+		Response:        harResponse,
+		Timings:         timings,
+	}
+
+	if addr, ok := req.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+			entry.ServerIPAddress = host
+		}
+	}
+
+	// Like for the entries of a client, the client port identifies the
+	// connection.
+	if _, port, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		entry.Connection = port
+	}
+
+	_ = h.writer.Write(entry)
+}
+
+// serverRequest returns a shallow copy of a request received by a server,
+// with an absolute URL, as required for entries.
+func serverRequest(req *http.Request) *http.Request {
+	u := *req.URL
+	u.Host = req.Host
+	u.Scheme = "http"
+	if req.TLS != nil {
+		u.Scheme = "https"
+	}
+
+	recorded := req.WithContext(req.Context())
+	recorded.URL = &u
+	return recorded
+}
+
+// recordingResponseWriter records the response written by a handler,
+// as it is written.
+//
+// At most limit bytes of the body are recorded, unless limit is negative,
+// but all bytes that are written are counted.
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	limit int
+
+	status    int
+	header    http.Header
+	firstByte time.Time
+	body      bytes.Buffer
+	size      int
+	hijacked  bool
+}
+
+func (w *recordingResponseWriter) WriteHeader(status int) {
+	// Informational responses, other than 101 Switching Protocols,
+	// are followed by the actual response.
+	if w.status == 0 && (status >= 200 || status == http.StatusSwitchingProtocols) {
+		w.started(status)
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.started(http.StatusOK)
+	}
+
+	n, err := w.ResponseWriter.Write(p)
+	w.record(p[:n])
+
+	return n, err
+}
+
+// Flush implements http.Flusher.
+// It does nothing if the underlying http.ResponseWriter cannot flush.
+func (w *recordingResponseWriter) Flush() {
+	_ = w.FlushError()
+}
+
+// FlushError is used by http.ResponseController to flush the response.
+func (w *recordingResponseWriter) FlushError() error {
+	if w.status == 0 {
+		w.started(http.StatusOK)
+	}
+
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack implements http.Hijacker.
+func (w *recordingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap is used by http.ResponseController to reach the features of the
+// underlying http.ResponseWriter, such as deadlines.
+func (w *recordingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// started records the start of the response,
+// at which point the headers are sent.
+func (w *recordingResponseWriter) started(status int) {
+	w.status = status
+	w.header = w.ResponseWriter.Header().Clone()
+	w.firstByte = time.Now()
+}
+
+func (w *recordingResponseWriter) record(p []byte) {
+	w.size += len(p)
+
+	if w.limit >= 0 {
+		remaining := w.limit - w.body.Len()
+		if remaining < len(p) {
+			p = p[:remaining]
+		}
+	}
+
+	w.body.Write(p)
+}
+
+// response returns the recorded response.
+func (w *recordingResponseWriter) response(req *http.Request) har.Response {
+	status, header := w.status, w.header
+	if status == 0 && !w.hijacked {
+		// The server sends an empty response for handlers that write nothing.
+		status = http.StatusOK
+	}
+	if header == nil {
+		header = w.ResponseWriter.Header().Clone()
+	}
+
+	// Like the server, detect the content type of responses without one.
+	body := w.body.Bytes()
+	if _, ok := header["Content-Type"]; !ok && len(body) > 0 && header.Get("Transfer-Encoding") == "" {
+		header = header.Clone()
+		header.Set("Content-Type", http.DetectContentType(body))
+	}
+
+	res := &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         req.Proto,
+		ProtoMajor:    req.ProtoMajor,
+		ProtoMinor:    req.ProtoMinor,
+		Header:        header,
+		ContentLength: -1,
+	}
+
+	harResponse := har.ResponseFromHttpResponse(res, har.WithoutBody())
+
+	if w.hijacked {
+		harResponse.Comment = "the connection was hijacked"
+		return harResponse
+	}
+
+	if body == nil {
+		body = []byte{}
+	}
+	harResponse.SetBody(body, w.size, har.WithMaxBodySize(w.limit))
+
+	return harResponse
+}
@@ -0,0 +1,63 @@
+package harwriter
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerRequestBody(t *testing.T) {
+	body := strings.Repeat("x", 5000)
+
+	tests := []struct {
+		name      string
+		read      int
+		text      string
+		truncated bool
+	}{
+		{name: "ignored", read: 0, text: "", truncated: true},
+		{name: "partly read", read: 100, text: body[:100], truncated: true},
+		{name: "read", read: len(body), text: body},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sink := NewMemorySink()
+			writer := NewEntryWriter(sink)
+
+			handler := writer.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.ReadFull(r.Body, make([]byte, test.read))
+			}))
+
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			res, err := http.Post(server.URL, "text/plain", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = res.Body.Close()
+
+			entries := sink.Entries()
+			if len(entries) != 1 {
+				t.Fatalf("len(Entries()) = %d, want 1", len(entries))
+			}
+
+			request := entries[0].Request
+			if request.BodySize != len(body) {
+				t.Errorf("BodySize = %d, want %d", request.BodySize, len(body))
+			}
+			if request.PostData.Text != test.text || request.PostData.Truncated != test.truncated {
+				t.Errorf(
+					"len(Text), Truncated = %d, %v, want %d, %v",
+					len(request.PostData.Text), request.PostData.Truncated, len(test.text), test.truncated,
+				)
+			}
+			if err := entries[0].Validate(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package harwriter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/oliverroer/go-har"
+)
+
+type pageRefKey struct{}
+
+// ContextWithPageRef returns a copy of ctx that refers to the page with the
+// given ID. Entries recorded for requests made with the returned context
+// refer to that page.
+func ContextWithPageRef(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, pageRefKey{}, id)
+}
+
+// PageRefFromContext returns the ID of the page that ctx refers to, if any.
+func PageRefFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(pageRefKey{}).(string)
+	return id
+}
+
+// PageRecorder records a page, which groups the entries of the requests made
+// with its context, e.g. the requests of a user flow or a test case.
+type PageRecorder struct {
+	writer *EntryWriter
+	once   sync.Once
+
+	mu   sync.Mutex
+	page har.Page
+}
+
+// StartPage starts recording a page with the given title.
+//
+// Requests made with the returned context, through the http.RoundTripper of
+// any EntryWriter, are recorded as entries that refer to the page.
+// The page itself is written to w when it ends.
+func (w *EntryWriter) StartPage(ctx context.Context, title string) (context.Context, *PageRecorder) {
+	page := &PageRecorder{
+		writer: w,
+		page: har.Page{
+			StartedDateTime: time.Now(),
+			ID:              newPageID(),
+			Title:           title,
+			PageTimings: har.PageTimings{
+				OnContentLoad: -1,
+				OnLoad:        -1,
+			},
+		},
+	}
+
+	return ContextWithPageRef(ctx, page.page.ID), page
+}
+
+// newPageID returns a random page ID, so that pages recorded by different
+// processes can be combined in a single log.
+func newPageID() string {
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+	return "page_" + hex.EncodeToString(id[:])
+}
+
+// ID returns the ID of the page.
+func (p *PageRecorder) ID() string {
+	return p.page.ID
+}
+
+// ContentLoaded records the time at which the content of the page was loaded,
+// relative to the start of the page.
+func (p *PageRecorder) ContentLoaded() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.page.PageTimings.OnContentLoad = sinceMillis(p.page.StartedDateTime)
+}
+
+// End records the time at which the page was loaded,
+// relative to the start of the page, and writes the page.
+// Only the first call has an effect.
+func (p *PageRecorder) End() error {
+	var err error
+	p.once.Do(func() {
+		p.mu.Lock()
+		p.page.PageTimings.OnLoad = sinceMillis(p.page.StartedDateTime)
+		page := p.page
+		p.mu.Unlock()
+
+		err = p.writer.WritePage(page)
+	})
+	return err
+}
+
+func sinceMillis(start time.Time) float64 {
+	return millis(start, time.Now())
+}
@@ -0,0 +1,221 @@
+package harwriter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/oliverroer/go-har"
+)
+
+var _ PageSink = (*RotatingSink)(nil)
+
+// RotateOptions decides when a RotatingSink rolls over to a new file.
+// Zero values disable the corresponding limit.
+type RotateOptions struct {
+	// MaxBytes is the number of bytes after which a file is completed.
+	// A file can exceed this size by at most one entry.
+	//
+	// For compressed files, the bytes are counted before compression,
+	// since the compressed size is only known once the compressor flushes,
+	// so the files are smaller than this on disk.
+	MaxBytes int64
+
+	// MaxEntries is the number of entries after which a file is completed.
+	MaxEntries int
+
+	// Interval is the duration after which a file is completed.
+	// The age of a file is checked whenever an entry is written or the sink is
+	// flushed.
+	Interval time.Duration
+
+	// MaxFiles is the number of files to keep, including the current one.
+	// When a new file is started, the oldest completed files are removed.
+	MaxFiles int
+
+	// Extension is the extension of the files, ".jsonl" by default.
+	// Use an extension with a registered compression, e.g. ".jsonl.gz",
+	// to compress the files. See RegisterCompression.
+	Extension string
+}
+
+// RotatingSink writes entries to a series of files in a directory,
+// one JSON encoded entry per line.
+//
+// Files are named like DefaultName, with the extension from RotateOptions,
+// and are only created once there is an entry to write to them.
+type RotatingSink struct {
+	dir     string
+	options RotateOptions
+
+	// mu guards completed, which may be read while entries are written.
+	mu        sync.Mutex
+	completed []string
+
+	current   *FileSink
+	startedAt time.Time
+	entries   int
+}
+
+// NewRotatingSink writes entries to files in dir, creating it if necessary.
+func NewRotatingSink(dir string, options RotateOptions) (*RotatingSink, error) {
+	dir = filepath.Clean(dir)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+
+	if options.Extension == "" {
+		options.Extension = ".jsonl"
+	}
+
+	sink := RotatingSink{
+		dir:     dir,
+		options: options,
+	}
+
+	return &sink, nil
+}
+
+// Files returns the names of the completed files that have not been removed,
+// oldest first.
+// The names can be passed on to EntriesToHar.
+func (s *RotatingSink) Files() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.completed)
+}
+
+func (s *RotatingSink) WriteEntry(entry har.Entry) error {
+	if err := s.prepare(); err != nil {
+		return err
+	}
+
+	if err := s.current.WriteEntry(entry); err != nil {
+		return err
+	}
+	s.entries++
+
+	return s.completeIfFull()
+}
+
+// WritePage writes a page to the current file.
+// Pages do not count towards MaxEntries.
+func (s *RotatingSink) WritePage(page har.Page) error {
+	if err := s.prepare(); err != nil {
+		return err
+	}
+
+	if err := s.current.WritePage(page); err != nil {
+		return err
+	}
+
+	return s.completeIfFull()
+}
+
+// prepare makes sure there is a current file that has not expired.
+func (s *RotatingSink) prepare() error {
+	if err := s.rotateIfExpired(); err != nil {
+		return err
+	}
+
+	if s.current == nil {
+		return s.start()
+	}
+
+	return nil
+}
+
+func (s *RotatingSink) completeIfFull() error {
+	full := (s.options.MaxBytes > 0 && s.current.UncompressedSize() >= s.options.MaxBytes) ||
+		(s.options.MaxEntries > 0 && s.entries >= s.options.MaxEntries)
+	if full {
+		return s.complete()
+	}
+
+	return nil
+}
+
+func (s *RotatingSink) Flush() error {
+	if err := s.rotateIfExpired(); err != nil {
+		return err
+	}
+
+	if s.current == nil {
+		return nil
+	}
+
+	return s.current.Flush()
+}
+
+// Close completes the current file, if any.
+func (s *RotatingSink) Close() error {
+	if s.current == nil {
+		return nil
+	}
+
+	return s.complete()
+}
+
+func (s *RotatingSink) rotateIfExpired() error {
+	if s.current == nil || s.options.Interval <= 0 {
+		return nil
+	}
+
+	if time.Since(s.startedAt) < s.options.Interval {
+		return nil
+	}
+
+	return s.complete()
+}
+
+// start creates a new current file,
+// and removes the oldest completed files, if there are too many.
+func (s *RotatingSink) start() error {
+	name := filepath.Join(s.dir, DefaultName()+s.options.Extension)
+
+	file, err := NewFileSink(name)
+	if err != nil {
+		return err
+	}
+
+	s.current = file
+	s.startedAt = time.Now()
+	s.entries = 0
+
+	if s.options.MaxFiles <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for len(s.completed) > 0 && len(s.completed)+1 > s.options.MaxFiles {
+		oldest := s.completed[0]
+		s.completed = s.completed[1:]
+
+		if err := os.Remove(oldest); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// complete closes the current file and adds it to the completed files.
+func (s *RotatingSink) complete() error {
+	current := s.current
+	s.current = nil
+
+	err := errors.Join(current.Flush(), current.Close())
+
+	s.mu.Lock()
+	s.completed = append(s.completed, current.Name())
+	s.mu.Unlock()
+
+	return err
+}
@@ -0,0 +1,90 @@
+package harwriter
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/oliverroer/go-har"
+)
+
+func TestRotatingSinkMaxBytes(t *testing.T) {
+	for _, extension := range []string{".jsonl", ".jsonl.gz"} {
+		t.Run(extension, func(t *testing.T) {
+			const maxBytes = 1000
+
+			sink, err := NewRotatingSink(t.TempDir(), RotateOptions{
+				MaxBytes:  maxBytes,
+				Extension: extension,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entry := har.Entry{
+				Request: har.Request{Method: "GET", URL: "https://example.com/"},
+			}
+			encoded, err := json.Marshal(entry)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entrySize := len(encoded) + 1
+
+			const entries = 200
+			for range entries {
+				if err := sink.WriteEntry(entry); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			files := sink.Files()
+			if len(files) < entries*entrySize/(maxBytes+entrySize) {
+				t.Fatalf("len(Files()) = %d, want files of at most %d bytes", len(files), maxBytes+entrySize)
+			}
+
+			total := 0
+			for _, name := range files {
+				size, lines := readEntryFileSize(t, name)
+				total += lines
+
+				// A file is completed by the first entry that reaches MaxBytes.
+				if size >= maxBytes+entrySize {
+					t.Errorf("%s has %d bytes, want less than %d", name, size, maxBytes+entrySize)
+				}
+			}
+
+			if total != entries {
+				t.Errorf("files hold %d entries, want %d", total, entries)
+			}
+		})
+	}
+}
+
+// readEntryFileSize returns the uncompressed size of an entry file,
+// and its number of lines.
+func readEntryFileSize(t *testing.T, name string) (int, int) {
+	t.Helper()
+
+	file, err := OpenEntryFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := 0
+	for _, b := range data {
+		if b == '\n' {
+			lines++
+		}
+	}
+
+	return len(data), lines
+}
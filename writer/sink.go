@@ -0,0 +1,333 @@
+package harwriter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/oliverroer/go-har"
+)
+
+// Sink is the destination of the entries written by an EntryWriter.
+//
+// An EntryWriter never calls the methods of its Sink concurrently,
+// so implementations do not need to be safe for concurrent use.
+type Sink interface {
+	// WriteEntry writes a single entry.
+	WriteEntry(entry har.Entry) error
+
+	// Flush makes sure that the written entries are persisted,
+	// e.g. by syncing them to disk.
+	Flush() error
+
+	// Close flushes and releases the resources held by the sink.
+	Close() error
+}
+
+// PageSink is implemented by sinks that can write pages alongside entries.
+type PageSink interface {
+	Sink
+
+	// WritePage writes a single page.
+	WritePage(page har.Page) error
+}
+
+var (
+	_ PageSink = (*FileSink)(nil)
+	_ PageSink = (*WriterSink)(nil)
+	_ PageSink = (*MemorySink)(nil)
+
+	_ Sink = (*FileSink)(nil)
+	_ Sink = (*WriterSink)(nil)
+	_ Sink = (*MemorySink)(nil)
+	_ Sink = (*ChanSink)(nil)
+)
+
+// FileSink writes entries to a file, one JSON encoded entry per line,
+// optionally compressed.
+type FileSink struct {
+	name       string
+	file       *os.File
+	counter    *countingWriter
+	compressor io.WriteCloser
+	encoded    *countingWriter
+	encoder    *json.Encoder
+}
+
+// FileOption configures a FileSink.
+type FileOption func(*fileOptions)
+
+type fileOptions struct {
+	compression *string
+}
+
+// WithCompression compresses the file using the compression registered for
+// the given extension, regardless of the extension of the file name.
+// An empty extension disables compression.
+func WithCompression(extension string) FileOption {
+	return func(o *fileOptions) {
+		o.compression = &extension
+	}
+}
+
+// NewFileSink creates (or truncates) the named file and writes entries to it.
+//
+// If the extension of the name has a registered compression,
+// e.g. ".jsonl.gz", the file is compressed. See RegisterCompression.
+func NewFileSink(name string, opts ...FileOption) (*FileSink, error) {
+	var options fileOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	c, compressed := compressionForName(name)
+	if options.compression != nil {
+		c, compressed = lookupCompression(*options.compression)
+		if !compressed && *options.compression != "" {
+			return nil, fmt.Errorf(
+				"harwriter: no compression registered for %q",
+				*options.compression,
+			)
+		}
+	}
+
+	cleaned := filepath.Clean(name)
+	file, err := os.Create(cleaned)
+	if err != nil {
+		return nil, err
+	}
+
+	counter := &countingWriter{writer: file}
+
+	sink := FileSink{
+		name:    name,
+		file:    file,
+		counter: counter,
+	}
+
+	var writer io.Writer = counter
+	if compressed {
+		sink.compressor, err = c.compressor(counter)
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		writer = sink.compressor
+	}
+
+	sink.encoded = &countingWriter{writer: writer}
+	sink.encoder = json.NewEncoder(sink.encoded)
+
+	return &sink, nil
+}
+
+// Name returns the name of the file.
+func (s *FileSink) Name() string {
+	return s.name
+}
+
+// Size returns the number of bytes written to the file.
+// For compressed files, this is the compressed size of the entries written
+// up until the last flush.
+func (s *FileSink) Size() int64 {
+	return s.counter.count
+}
+
+// UncompressedSize returns the number of bytes of the entries and pages
+// written to the file, before compression.
+// For files that are not compressed, this is the same as Size.
+func (s *FileSink) UncompressedSize() int64 {
+	return s.encoded.count
+}
+
+func (s *FileSink) WriteEntry(entry har.Entry) error {
+	return s.encoder.Encode(entry)
+}
+
+// WritePage writes a page on a line of its own, like an entry.
+func (s *FileSink) WritePage(page har.Page) error {
+	return s.encoder.Encode(page)
+}
+
+// Flush flushes the compressor, if any, and syncs the file to disk.
+func (s *FileSink) Flush() error {
+	if flusher, ok := s.compressor.(interface{ Flush() error }); ok {
+		if err := flusher.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return s.file.Sync()
+}
+
+// Close closes the compressor, if any, which writes the end of the compressed
+// stream, and closes the file.
+func (s *FileSink) Close() error {
+	var err error
+	if s.compressor != nil {
+		err = s.compressor.Close()
+	}
+
+	return errors.Join(err, s.file.Close())
+}
+
+// countingWriter counts the bytes written to the underlying writer.
+type countingWriter struct {
+	writer io.Writer
+	count  int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.count += int64(n)
+	return n, err
+}
+
+// WriterSink writes entries to an io.Writer, one JSON encoded entry per line.
+type WriterSink struct {
+	writer  io.Writer
+	encoder *json.Encoder
+}
+
+// NewWriterSink writes entries to w.
+//
+// If w has a Flush or Sync method, such as a *bufio.Writer or an *os.File,
+// it is called when the sink is flushed.
+// The sink does not close w.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{
+		writer:  w,
+		encoder: json.NewEncoder(w),
+	}
+}
+
+func (s *WriterSink) WriteEntry(entry har.Entry) error {
+	return s.encoder.Encode(entry)
+}
+
+// WritePage writes a page on a line of its own, like an entry.
+func (s *WriterSink) WritePage(page har.Page) error {
+	return s.encoder.Encode(page)
+}
+
+func (s *WriterSink) Flush() error {
+	switch w := s.writer.(type) {
+	case interface{ Flush() error }:
+		return w.Flush()
+
+	case interface{ Sync() error }:
+		return w.Sync()
+
+	default:
+		return nil
+	}
+}
+
+// Close flushes the underlying writer, but does not close it.
+func (s *WriterSink) Close() error {
+	return s.Flush()
+}
+
+// MemorySink keeps entries and pages in memory,
+// which is mostly useful in tests.
+// It is safe to read the entries and pages while they are being written.
+type MemorySink struct {
+	mu      sync.Mutex
+	entries []har.Entry
+	pages   []har.Page
+}
+
+// NewMemorySink creates an empty MemorySink.
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// Entries returns a copy of the entries written so far.
+func (s *MemorySink) Entries() []har.Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.entries)
+}
+
+// Pages returns a copy of the pages written so far.
+func (s *MemorySink) Pages() []har.Page {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.pages)
+}
+
+func (s *MemorySink) WriteEntry(entry har.Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *MemorySink) WritePage(page har.Page) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pages = append(s.pages, page)
+	return nil
+}
+
+func (s *MemorySink) Flush() error {
+	return nil
+}
+
+func (s *MemorySink) Close() error {
+	return nil
+}
+
+// ChanSink sends entries on a channel. Pages are not supported.
+// Writes block until the entry has been received, or until there is room in
+// the buffer of the channel.
+type ChanSink struct {
+	entries chan har.Entry
+	closed  bool
+}
+
+// NewChanSink creates a ChanSink whose channel has the given buffer size.
+func NewChanSink(buffer int) *ChanSink {
+	return &ChanSink{
+		entries: make(chan har.Entry, buffer),
+	}
+}
+
+// Entries returns the channel that entries are sent on.
+// The channel is closed when the sink is closed.
+func (s *ChanSink) Entries() <-chan har.Entry {
+	return s.entries
+}
+
+// WriteEntry sends the entry on the channel,
+// or returns ErrClosed if the sink has been closed.
+func (s *ChanSink) WriteEntry(entry har.Entry) error {
+	if s.closed {
+		return ErrClosed
+	}
+
+	s.entries <- entry
+	return nil
+}
+
+func (s *ChanSink) Flush() error {
+	return nil
+}
+
+// Close closes the channel. Closing the sink more than once has no effect.
+func (s *ChanSink) Close() error {
+	if !s.closed {
+		s.closed = true
+		close(s.entries)
+	}
+	return nil
+}
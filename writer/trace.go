@@ -0,0 +1,243 @@
+package harwriter
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/oliverroer/go-har"
+)
+
+// roundTripTrace records the points in time that make up a single round trip,
+// as reported by net/http/httptrace.
+//
+// The hooks of a httptrace.ClientTrace may be called from other goroutines
+// than the one performing the round trip (e.g. when dialing), so all access
+// is guarded by a mutex.
+type roundTripTrace struct {
+	mu sync.Mutex
+
+	start        time.Time
+	getConn      time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	gotConn      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	end          time.Time
+
+	serverIPAddress string
+	connection      string
+}
+
+func newRoundTripTrace() *roundTripTrace {
+	return &roundTripTrace{
+		start: time.Now(),
+	}
+}
+
+func (t *roundTripTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.mark(&t.getConn)
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mark(&t.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mark(&t.dnsDone)
+		},
+		ConnectStart: func(string, string) {
+			t.mark(&t.connectStart)
+		},
+		ConnectDone: func(string, string, error) {
+			// When multiple addresses are dialed in parallel, the connect phase
+			// lasts until the last attempt is done.
+			t.mu.Lock()
+			t.connectDone = time.Now()
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.mark(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mark(&t.tlsDone)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			if t.gotConn.IsZero() {
+				t.gotConn = time.Now()
+			}
+
+			if info.Conn == nil {
+				return
+			}
+
+			if host, _, err := net.SplitHostPort(info.Conn.RemoteAddr().String()); err == nil {
+				t.serverIPAddress = host
+			}
+
+			if _, port, err := net.SplitHostPort(info.Conn.LocalAddr().String()); err == nil {
+				t.connection = port
+			}
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.mark(&t.wroteRequest)
+		},
+		GotFirstResponseByte: func() {
+			t.mark(&t.firstByte)
+		},
+	}
+}
+
+// mark sets the given point in time to now, unless it has already been set.
+func (t *roundTripTrace) mark(at *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if at.IsZero() {
+		*at = time.Now()
+	}
+}
+
+// responded is called when the response headers have been returned by the
+// underlying round tripper.
+// If the round tripper does not support tracing, this is the best estimate
+// we have of when the first response byte arrived.
+func (t *roundTripTrace) responded() {
+	t.mark(&t.firstByte)
+}
+
+// finish is called when the round trip is over, either because the response
+// body has been read in its entirety or because the round trip failed.
+func (t *roundTripTrace) finish() {
+	t.mark(&t.end)
+}
+
+// timings breaks the round trip down into the phases of har.Timings.
+//
+// Phases that did not occur are set to -1 where the spec allows it,
+// and to 0 otherwise.
+// If the round trip ended prematurely, the phase that was in progress is
+// considered to last until the end of the round trip.
+func (t *roundTripTrace) timings() har.Timings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	end := t.end
+	if end.IsZero() {
+		end = time.Now()
+	}
+
+	timings := har.Timings{
+		Blocked: -1,
+		DNS:     -1,
+		Connect: -1,
+		SSL:     -1,
+	}
+
+	traced := !t.getConn.IsZero()
+
+	if traced {
+		// Blocked lasts until we start acquiring a connection of our own,
+		// or until we are handed an existing one.
+		blockedEnd := firstOf(t.dnsStart, t.connectStart, t.tlsStart, t.gotConn, end)
+		timings.Blocked = millis(t.start, blockedEnd)
+	}
+
+	if !t.dnsStart.IsZero() {
+		dnsEnd := firstOf(t.dnsDone, t.connectStart, end)
+		timings.DNS = millis(t.dnsStart, dnsEnd)
+	}
+
+	if connectStart := firstOf(t.connectStart, t.tlsStart); !connectStart.IsZero() {
+		// The SSL time is included in the connect time.
+		connectEnd := lastOf(t.connectDone, t.tlsDone)
+		if connectEnd.IsZero() {
+			connectEnd = firstOf(t.gotConn, end)
+		}
+		timings.Connect = millis(connectStart, connectEnd)
+	}
+
+	if !t.tlsStart.IsZero() {
+		tlsEnd := firstOf(t.tlsDone, t.gotConn, end)
+		timings.SSL = millis(t.tlsStart, tlsEnd)
+	}
+
+	if !t.gotConn.IsZero() {
+		sendEnd := firstOf(t.wroteRequest, t.firstByte, end)
+		timings.Send = millis(t.gotConn, sendEnd)
+	}
+
+	// Without any tracing information, everything up until the first byte of
+	// the response is spent waiting.
+	waitStart := t.wroteRequest
+	if !traced {
+		waitStart = t.start
+	}
+	if !waitStart.IsZero() {
+		waitEnd := firstOf(t.firstByte, end)
+		timings.Wait = millis(waitStart, waitEnd)
+	}
+
+	if !t.firstByte.IsZero() {
+		timings.Receive = millis(t.firstByte, end)
+	}
+
+	return timings
+}
+
+// connectionInfo returns the IP address of the server and the local port of
+// the connection that was used, if any.
+func (t *roundTripTrace) connectionInfo() (serverIPAddress string, connection string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.serverIPAddress, t.connection
+}
+
+// firstOf returns the first of the given points in time that has been set,
+// in argument order.
+func firstOf(times ...time.Time) time.Time {
+	for _, at := range times {
+		if !at.IsZero() {
+			return at
+		}
+	}
+	return time.Time{}
+}
+
+// lastOf returns the latest of the given points in time.
+func lastOf(times ...time.Time) time.Time {
+	var last time.Time
+	for _, at := range times {
+		if at.After(last) {
+			last = at
+		}
+	}
+	return last
+}
+
+// millis returns the number of milliseconds between from and to,
+// or 0 if to is before from.
+func millis(from, to time.Time) float64 {
+	duration := to.Sub(from)
+	if duration < 0 {
+		return 0
+	}
+	return durationMillis(duration)
+}
+
+// durationMillis returns the duration in milliseconds,
+// with a precision of microseconds.
+func durationMillis(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1000
+}
@@ -2,7 +2,7 @@ package harwriter
 
 import (
 	"net/http"
-	"time"
+	"net/http/httptrace"
 
 	"github.com/oliverroer/go-har"
 )
@@ -10,20 +10,202 @@ import (
 var _ http.RoundTripper = (*harRoundTripper)(nil)
 
 type harRoundTripper struct {
-	base   http.RoundTripper
-	writer *EntryWriter
+	base                http.RoundTripper
+	writer              *EntryWriter
+	streaming           bool
+	maxRequestBodySize  int
+	maxResponseBodySize int
+	filters             []Filter
+}
+
+// TransportOption configures the http.RoundTripper returned by
+// EntryWriter.RoundTripper.
+type TransportOption func(*harRoundTripper)
+
+// WithStreaming records response bodies as they are read by the caller,
+// instead of reading them in their entirety before returning the response.
+//
+// This keeps streaming responses (e.g. downloads, long-polling and
+// Server-Sent Events) working, and makes recording have no effect on the time
+// until the caller receives the response.
+// The entry is written once the response body has been read in its entirety
+// or has been closed, so callers must make sure to do either.
+// Bodies that are closed before they have been read in their entirety are
+// recorded as truncated.
+func WithStreaming() TransportOption {
+	return func(t *harRoundTripper) {
+		t.streaming = true
+	}
+}
+
+// WithMaxRequestBodySize limits the number of request body bytes that are
+// recorded to n.
+// Entries with truncated request bodies still hold the actual body size,
+// and their posted data is marked as truncated.
+//
+// Request bodies are recorded as they are sent, so no more than n bytes of
+// a request body are ever held in memory for recording.
+func WithMaxRequestBodySize(n int) TransportOption {
+	return func(t *harRoundTripper) {
+		t.maxRequestBodySize = n
+	}
+}
+
+// WithMaxResponseBodySize limits the number of response body bytes that are
+// recorded to n.
+// Entries with truncated response bodies still hold the actual body size,
+// and their content is marked as truncated.
+//
+// When combined with WithStreaming, no more than n bytes of a response body
+// are ever held in memory for recording.
+func WithMaxResponseBodySize(n int) TransportOption {
+	return func(t *harRoundTripper) {
+		t.maxResponseBodySize = n
+	}
 }
 
 func (t *harRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	harRequest := har.RequestFromHttpRequest(req)
+	// Nothing is captured for round trips that are excluded by their request.
+	if !t.mayInclude(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	harRequest := har.RequestFromHttpRequest(req, har.WithoutBody())
+
+	trace := newRoundTripTrace()
+	ctx := httptrace.WithClientTrace(req.Context(), trace.clientTrace())
+	req = req.WithContext(ctx)
+
+	// The request body is recorded as it is sent, rather than being read
+	// in advance.
+	var requestBody *recordingBody
+	if req.Body != nil && req.Body != http.NoBody {
+		requestBody = &recordingBody{
+			body:  req.Body,
+			limit: t.maxRequestBodySize,
+			done:  func([]byte, int, bool) {},
+		}
+		req.Body = requestBody
+	}
 
-	start := time.Now()
 	res, err := t.base.RoundTrip(req)
-	elapsed := time.Since(start)
+	if !t.included(req, res) {
+		return res, err
+	}
+
+	if err != nil {
+		trace.finish()
+		t.writeEntry(req, trace, harRequest, requestBody, har.ResponseFromError(err))
+		return nil, err
+	}
+	trace.responded()
+
+	if t.streaming {
+		harResponse := har.ResponseFromHttpResponse(res, har.WithoutBody())
+		contentLength := res.ContentLength
+		res.Body = newRecordingBody(res.Body, t.maxResponseBodySize, func(body []byte, size int, complete bool) {
+			trace.finish()
+
+			// The caller may close the body before reading all of it, in
+			// which case the recorded body is truncated.
+			known := true
+			if !complete {
+				size, known = unreadSize(size, contentLength)
+			}
+
+			if body != nil {
+				harResponse.SetBody(body, size, har.WithMaxBodySize(t.maxResponseBodySize))
+			}
+			if !known {
+				harResponse.Content.Comment = appendComment(harResponse.Content.Comment, unreadComment)
+			}
+
+			t.writeEntry(req, trace, harRequest, requestBody, harResponse)
+		})
+		return res, nil
+	}
+
+	// This reads the entire response body,
+	// so the round trip is over once we have converted the response.
+	harResponse := har.ResponseFromHttpResponse(
+		res,
+		har.WithMaxBodySize(t.maxResponseBodySize),
+	)
+	trace.finish()
+
+	t.writeEntry(req, trace, harRequest, requestBody, harResponse)
+
+	return res, nil
+}
+
+// mayInclude reports whether the round trip of the request may pass all
+// filters, which is not the case if a filter excludes it by its request alone.
+func (t *harRoundTripper) mayInclude(req *http.Request) bool {
+	for _, filter := range t.filters {
+		if matched, decided := filter.matchesRequest(req); decided && !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// included reports whether the round trip passes all filters,
+// and should be recorded.
+func (t *harRoundTripper) included(req *http.Request, res *http.Response) bool {
+	for _, filter := range t.filters {
+		if !filter.Match(req, res) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *harRoundTripper) writeEntry(
+	req *http.Request,
+	trace *roundTripTrace,
+	harRequest har.Request,
+	requestBody *recordingBody,
+	harResponse har.Response,
+) {
+	if requestBody != nil {
+		t.setRequestBody(&harRequest, requestBody, req.ContentLength)
+	}
+
+	timings := trace.timings()
+	serverIPAddress, connection := trace.connectionInfo()
+
+	entry := har.Entry{
+		Pageref:         PageRefFromContext(req.Context()),
+		StartedDateTime: trace.start,
+		Time:            timings.Total(),
+		Request:         harRequest,
+		Response:        harResponse,
+		Timings:         timings,
+		ServerIPAddress: serverIPAddress,
+		Connection:      connection,
+	}
+
+	_ = t.writer.Write(entry)
+}
 
-	harResponse := har.ResponseFromHttpResponse(res)
+// setRequestBody sets the posted data of the request to what has been
+// recorded of its body.
+// The base transport may still be sending the body, e.g. if the server
+// responded early, in which case the rest of the body is missing.
+func (t *harRoundTripper) setRequestBody(
+	harRequest *har.Request,
+	body *recordingBody,
+	contentLength int64,
+) {
+	recorded, size, complete := body.recorded()
 
-	_ = t.writer.WriteEntry(harRequest, harResponse, start, elapsed)
+	known := true
+	if !complete {
+		size, known = unreadSize(size, contentLength)
+	}
 
-	return res, err
+	harRequest.SetBody(recorded, size, har.WithMaxBodySize(t.maxRequestBodySize))
+	if !known {
+		harRequest.PostData.Comment = appendComment(harRequest.PostData.Comment, unreadComment)
+	}
 }
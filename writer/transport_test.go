@@ -0,0 +1,285 @@
+package harwriter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/oliverroer/go-har"
+)
+
+// TestTransportConcurrent makes requests from many goroutines through a
+// shared RoundTripper, and checks that every entry is written on a line of
+// its own, in the order the round trips completed.
+// Run it with -race to check for data races.
+func TestTransportConcurrent(t *testing.T) {
+	tests := []struct {
+		name          string
+		writerOpts    []Option
+		transportOpts []TransportOption
+	}{
+		{name: "sync"},
+		{name: "async", writerOpts: []Option{WithAsync(16, QueueBlock)}},
+		{name: "streaming", transportOpts: []TransportOption{WithStreaming()}},
+		{
+			name:          "async streaming",
+			writerOpts:    []Option{WithAsync(16, QueueBlock)},
+			transportOpts: []TransportOption{WithStreaming(), WithMaxResponseBodySize(64)},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = fmt.Fprintf(w, "%s %s %s", r.URL.RawQuery, body, strings.Repeat("x", 100))
+	}))
+	defer server.Close()
+
+	const (
+		goroutines = 8
+		requests   = 20
+	)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "entries.jsonl")
+			writer, err := Open(name, test.writerOpts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			client := &http.Client{
+				Transport: writer.RoundTripper(server.Client().Transport, test.transportOpts...),
+			}
+
+			var wg sync.WaitGroup
+			for g := range goroutines {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					for n := range requests {
+						url := fmt.Sprintf("%s/?g=%d&n=%d", server.URL, g, n)
+						res, err := client.Post(url, "text/plain", strings.NewReader("body"))
+						if err != nil {
+							t.Error(err)
+							return
+						}
+						_, _ = io.Copy(io.Discard, res.Body)
+						_ = res.Body.Close()
+					}
+				}()
+			}
+			wg.Wait()
+
+			if err := writer.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			entries := readEntryLines(t, name)
+			if len(entries) != goroutines*requests {
+				t.Fatalf("read %d entries, want %d", len(entries), goroutines*requests)
+			}
+
+			// Each goroutine makes its next request once the previous round
+			// trip has completed, so its entries must appear in order.
+			next := make(map[int]int)
+			for i, entry := range entries {
+				g, n := requestNumbers(t, entry)
+				if n != next[g] {
+					t.Fatalf("entry %d is request %d of goroutine %d, want request %d", i, n, g, next[g])
+				}
+				next[g]++
+
+				if entry.Request.PostData == nil || entry.Request.PostData.Text != "body" {
+					t.Errorf("entry %d has posted data %+v, want body", i, entry.Request.PostData)
+				}
+				if !strings.HasPrefix(entry.Response.Content.Text, fmt.Sprintf("g=%d&n=%d body", g, n)) {
+					t.Errorf("entry %d has content %q, want the response to its request", i, entry.Response.Content.Text)
+				}
+			}
+		})
+	}
+}
+
+// readEntryLines decodes every line of an entry file as an entry,
+// failing the test if any line does not decode.
+func readEntryLines(t *testing.T, name string) []har.Entry {
+	t.Helper()
+
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var entries []har.Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var entry har.Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("line %d: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return entries
+}
+
+// requestNumbers returns the goroutine and request numbers of the entry,
+// from its query string.
+func requestNumbers(t *testing.T, entry har.Entry) (int, int) {
+	t.Helper()
+
+	numbers := make(map[string]int)
+	for _, param := range entry.Request.QueryString {
+		number, err := strconv.Atoi(param.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		numbers[param.Name] = number
+	}
+
+	return numbers["g"], numbers["n"]
+}
+
+func TestTransportStreamingClosedEarly(t *testing.T) {
+	body := strings.Repeat("x", 10000)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		if r.URL.Path == "/chunked" {
+			// Flushing before the end of the body leaves its length unknown.
+			_, _ = io.WriteString(w, body[:5000])
+			http.NewResponseController(w).Flush()
+			_, _ = io.WriteString(w, body[5000:])
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		_, _ = io.WriteString(w, body)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path      string
+		size      int
+		truncated bool
+		comment   string
+	}{
+		{path: "/", size: len(body), truncated: true, comment: "truncated to 100 of 10000 bytes"},
+		{path: "/chunked", size: 100, comment: unreadComment},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			sink := NewMemorySink()
+			writer := NewEntryWriter(sink)
+			client := &http.Client{
+				Transport: writer.RoundTripper(server.Client().Transport, WithStreaming()),
+			}
+
+			res, err := client.Get(server.URL + test.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _ = io.ReadFull(res.Body, make([]byte, 100))
+			_ = res.Body.Close()
+
+			entries := sink.Entries()
+			if len(entries) != 1 {
+				t.Fatalf("len(Entries()) = %d, want 1", len(entries))
+			}
+
+			response := entries[0].Response
+			content := response.Content
+			if response.BodySize != test.size || content.Size != test.size {
+				t.Errorf("BodySize, Content.Size = %d, %d, want %d", response.BodySize, content.Size, test.size)
+			}
+			if len(content.Text) != 100 {
+				t.Errorf("len(Text) = %d, want 100", len(content.Text))
+			}
+			if content.Truncated != test.truncated || content.Comment != test.comment {
+				t.Errorf(
+					"Truncated, Comment = %v, %q, want %v, %q",
+					content.Truncated, content.Comment, test.truncated, test.comment,
+				)
+			}
+		})
+	}
+}
+
+// countingReader counts the bytes read from it.
+type countingReader struct {
+	reader io.Reader
+	read   int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.read += n
+	return n, err
+}
+
+func TestTransportRequestBodyNotBuffered(t *testing.T) {
+	body := &countingReader{reader: strings.NewReader(strings.Repeat("x", 10000))}
+
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if body.read != 0 {
+			t.Errorf("%d bytes of the request body were read before it was sent", body.read)
+		}
+		_, _ = io.Copy(io.Discard, req.Body)
+		_ = req.Body.Close()
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	})
+
+	sink := NewMemorySink()
+	writer := NewEntryWriter(sink)
+	transport := writer.RoundTripper(base, WithMaxRequestBodySize(100))
+
+	req, err := http.NewRequest(http.MethodPost, "http://example.com/upload", io.NopCloser(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "text/plain")
+
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+
+	entries := sink.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("len(Entries()) = %d, want 1", len(entries))
+	}
+
+	request := entries[0].Request
+	if request.BodySize != 10000 {
+		t.Errorf("BodySize = %d, want 10000", request.BodySize)
+	}
+	if len(request.PostData.Text) != 100 || !request.PostData.Truncated {
+		t.Errorf(
+			"len(Text), Truncated = %d, %v, want 100, true",
+			len(request.PostData.Text), request.PostData.Truncated,
+		)
+	}
+}
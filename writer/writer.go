@@ -1,21 +1,75 @@
 package harwriter
 
 import (
-	"bufio"
-	"encoding/json"
-	"fmt"
+	"context"
+	"errors"
 	"net/http"
-	"os"
-	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/oliverroer/go-har"
 )
 
+// EntryWriter writes HAR entries to a Sink, e.g. to a file with one JSON
+// encoded entry per line.
+//
+// An EntryWriter is safe for concurrent use by multiple goroutines,
+// as is the case for the http.RoundTripper it hands out.
+// Each entry is written in its entirety before the next one is started,
+// in the order the writes are made, so entries are ordered by the time their
+// round trip completed rather than by the time it started.
+//
+// By default, entries are written synchronously, and each write waits for the
+// sink to be flushed. See WithAsync for writing in the background.
 type EntryWriter struct {
-	name    string
-	file    *os.File
-	encoder *json.Encoder
+	mu       sync.Mutex
+	sink     Sink
+	closed   bool
+	queue    *asyncQueue
+	redactor *har.Redactor
+}
+
+// record is either an entry or a page, as written to a sink.
+type record struct {
+	entry har.Entry
+	page  *har.Page
+}
+
+// Option configures an EntryWriter.
+type Option func(*options)
+
+type options struct {
+	async       bool
+	queueSize   int
+	queuePolicy QueuePolicy
+	redactor    *har.Redactor
+}
+
+// WithAsync makes the EntryWriter write entries in the background.
+//
+// Writes only add the entry to a queue that holds up to queueSize entries,
+// and policy decides what happens when the queue is full.
+// The number of entries dropped due to the policy is reported by Dropped.
+// The sink is flushed whenever the queue runs empty.
+//
+// Entries are still written in the order the writes are made.
+// Use Flush to wait for pending entries to be written,
+// and make sure to Close the EntryWriter to not lose any of them.
+func WithAsync(queueSize int, policy QueuePolicy) Option {
+	return func(o *options) {
+		o.async = true
+		o.queueSize = queueSize
+		o.queuePolicy = policy
+	}
+}
+
+// WithRedactor makes the EntryWriter redact every entry before it is
+// written, or queued for writing, so that sensitive data never reaches the
+// sink. See har.NewRedactor.
+func WithRedactor(redactor *har.Redactor) Option {
+	return func(o *options) {
+		o.redactor = redactor
+	}
 }
 
 func DefaultName() string {
@@ -25,113 +79,189 @@ func DefaultName() string {
 	return name
 }
 
-func Open(name string) (*EntryWriter, error) {
-	cleaned := filepath.Clean(name)
-	file, err := os.Create(cleaned)
+// Open creates (or truncates) the named file,
+// and returns an EntryWriter that writes entries to it.
+// The file is compressed if its extension has a registered compression,
+// e.g. ".jsonl.gz".
+func Open(name string, opts ...Option) (*EntryWriter, error) {
+	sink, err := NewFileSink(name)
 	if err != nil {
 		return nil, err
 	}
 
+	return NewEntryWriter(sink, opts...), nil
+}
+
+// NewEntryWriter returns an EntryWriter that writes entries to sink.
+// Closing the EntryWriter closes the sink.
+func NewEntryWriter(sink Sink, opts ...Option) *EntryWriter {
+	var options options
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	writer := EntryWriter{
-		name:    name,
-		file:    file,
-		encoder: json.NewEncoder(file),
+		sink:     sink,
+		redactor: options.redactor,
 	}
 
-	return &writer, nil
+	if options.async {
+		writer.queue = newAsyncQueue(options.queueSize, options.queuePolicy)
+		go writer.queue.run(writer.write, writer.flush)
+	}
+
+	return &writer
 }
 
+// WriteEntry writes an entry for a request that took the given duration.
+// Without a breakdown of the duration, it is all considered time spent
+// waiting for the response.
 func (w *EntryWriter) WriteEntry(
 	request har.Request,
 	response har.Response,
 	startedAt time.Time,
 	duration time.Duration,
 ) error {
+	timings := har.Timings{
+		Blocked: -1,
+		DNS:     -1,
+		Connect: -1,
+		SSL:     -1,
+		Wait:    durationMillis(duration),
+	}
+
 	entry := har.Entry{
 		StartedDateTime: startedAt,
-		Time:            int(duration.Milliseconds()),
+		Time:            timings.Total(),
 		Request:         request,
 		Response:        response,
+		Timings:         timings,
+	}
+
+	return w.Write(entry)
+}
+
+// Write writes a complete entry.
+//
+// If the EntryWriter is asynchronous, the entry is only queued for writing.
+func (w *EntryWriter) Write(entry har.Entry) error {
+	if w.redactor != nil {
+		entry = w.redactor.Redact(entry)
+	}
+
+	return w.writeRecord(record{entry: entry})
+}
+
+// WritePage writes a page, which entries can refer to.
+// Pages are written alongside the entries, if the sink is a PageSink,
+// and are discarded otherwise.
+//
+// If the EntryWriter is asynchronous, the page is only queued for writing.
+func (w *EntryWriter) WritePage(page har.Page) error {
+	if _, ok := w.sink.(PageSink); !ok {
+		return nil
+	}
+
+	return w.writeRecord(record{page: &page})
+}
+
+func (w *EntryWriter) writeRecord(rec record) error {
+	if w.queue != nil {
+		return w.queue.enqueue(rec)
 	}
 
-	err := w.encoder.Encode(entry)
+	err := w.write(rec)
 	if err != nil {
 		return err
 	}
 
-	_ = w.file.Sync()
+	_ = w.flush()
 
 	return nil
 }
 
-func (w *EntryWriter) Close() error {
-	return w.file.Close()
-}
+func (w *EntryWriter) write(rec record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-func (w *EntryWriter) RoundTripper(base http.RoundTripper) http.RoundTripper {
-	return &harRoundTripper{
-		base:   base,
-		writer: w,
+	if w.closed {
+		return ErrClosed
 	}
-}
 
-const harBegin = `{
-  "log": {
-    "version": "1.2",
-    "creator": {
-      "name": "github.com/oliverroer/go-har",
-      "version": "0.1.1"
-    },
-    "entries": [`
+	if rec.page != nil {
+		return w.sink.(PageSink).WritePage(*rec.page)
+	}
 
-const harEnd = `
-    ]
-  }
+	return w.sink.WriteEntry(rec.entry)
 }
-`
 
-func EntriesToHar(harFile string, entryFiles ...string) error {
-	harFile = filepath.Clean(harFile)
-	har, err := os.Create(harFile)
-	if err != nil {
-		return err
-	}
+func (w *EntryWriter) flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-	_, err = har.WriteString(harBegin)
-	if err != nil {
-		return err
+	if w.closed {
+		return ErrClosed
 	}
 
-	for _, entryFile := range entryFiles {
-		entryFile = filepath.Clean(entryFile)
-		file, err := os.Open(entryFile)
-		if err != nil {
+	return w.sink.Flush()
+}
+
+// Flush waits for all entries written before the call to be written to the
+// sink, and then flushes the sink, unless ctx is done before that.
+// It returns the first error encountered while writing in the background
+// since the last call to Flush, if any.
+func (w *EntryWriter) Flush(ctx context.Context) error {
+	if w.queue != nil {
+		if err := w.queue.wait(ctx); err != nil {
 			return err
 		}
-		defer file.Close()
+	}
 
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			line := scanner.Text()
+	return w.flush()
+}
 
-			str := fmt.Sprintf("\n      %s,", line)
-			_, err := har.WriteString(str)
-			if err != nil {
-				return err
-			}
-		}
+// Dropped returns the number of entries that have been dropped because the
+// queue of an asynchronous EntryWriter was full.
+func (w *EntryWriter) Dropped() uint64 {
+	if w.queue == nil {
+		return 0
 	}
+	return w.queue.dropped.Load()
+}
 
-	// step one byte back to discard the last trailing comma
-	_, err = har.Seek(-1, 1)
-	if err != nil {
-		return err
+// Close writes the pending entries of an asynchronous EntryWriter, and closes
+// the sink. Writing, flushing or closing afterwards returns ErrClosed.
+func (w *EntryWriter) Close() error {
+	var queueErr error
+	if w.queue != nil {
+		queueErr = w.queue.close()
 	}
 
-	_, err = har.WriteString(harEnd)
-	if err != nil {
-		return err
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+	w.closed = true
+
+	return errors.Join(queueErr, w.sink.Close())
+}
+
+func (w *EntryWriter) RoundTripper(
+	base http.RoundTripper,
+	opts ...TransportOption,
+) http.RoundTripper {
+	transport := &harRoundTripper{
+		base:                base,
+		writer:              w,
+		maxRequestBodySize:  -1,
+		maxResponseBodySize: -1,
+	}
+
+	for _, opt := range opts {
+		opt(transport)
 	}
 
-	return har.Close()
+	return transport
 }
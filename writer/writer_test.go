@@ -0,0 +1,79 @@
+package harwriter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/oliverroer/go-har"
+)
+
+func TestEntryWriterClosed(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "sync"},
+		{name: "async", opts: []Option{WithAsync(8, QueueBlock)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sink := NewMemorySink()
+			writer := NewEntryWriter(sink, test.opts...)
+
+			if err := writer.Write(har.Entry{}); err != nil {
+				t.Fatalf("Write() = %v", err)
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatalf("Close() = %v", err)
+			}
+
+			if err := writer.Write(har.Entry{}); !errors.Is(err, ErrClosed) {
+				t.Errorf("Write() after Close() = %v, want ErrClosed", err)
+			}
+			if err := writer.WritePage(har.Page{}); !errors.Is(err, ErrClosed) {
+				t.Errorf("WritePage() after Close() = %v, want ErrClosed", err)
+			}
+			if err := writer.Flush(context.Background()); !errors.Is(err, ErrClosed) {
+				t.Errorf("Flush() after Close() = %v, want ErrClosed", err)
+			}
+			if err := writer.Close(); !errors.Is(err, ErrClosed) {
+				t.Errorf("Close() after Close() = %v, want ErrClosed", err)
+			}
+
+			if got := len(sink.Entries()); got != 1 {
+				t.Errorf("len(Entries()) = %d, want 1", got)
+			}
+		})
+	}
+}
+
+func TestChanSinkClosed(t *testing.T) {
+	sink := NewChanSink(1)
+
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Errorf("Close() after Close() = %v, want nil", err)
+	}
+	if err := sink.WriteEntry(har.Entry{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("WriteEntry() after Close() = %v, want ErrClosed", err)
+	}
+
+	if _, ok := <-sink.Entries(); ok {
+		t.Error("Entries() is not closed")
+	}
+}
+
+func TestEntryWriterChanSinkClosed(t *testing.T) {
+	writer := NewEntryWriter(NewChanSink(1))
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if err := writer.Write(har.Entry{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Write() after Close() = %v, want ErrClosed", err)
+	}
+}